@@ -0,0 +1,106 @@
+package anderson
+
+import "sort"
+
+// Baseline is a record of the findings that were already known about when
+// anderson was adopted. Dependencies that still match their entry exactly do
+// not fail the build.
+type Baseline struct {
+	Entries []BaselineEntry `yaml:"entries"`
+}
+
+// BaselineEntry is a single accepted finding. ImportPath is the import path
+// of the directory that the license was found in and SHA256 has the
+// checksum of each of its license files.
+type BaselineEntry struct {
+	ImportPath string   `yaml:"import_path" json:"import_path"`
+	License    string   `yaml:"license" json:"license"`
+	Status     string   `yaml:"status" json:"status"`
+	SHA256     []string `yaml:"sha256" json:"sha256"`
+}
+
+// NewBaseline records every dependency in a report that fails the build.
+func NewBaseline(report Report) Baseline {
+	baseline := Baseline{Entries: []BaselineEntry{}}
+	for _, dependency := range report.Dependencies {
+		if dependency.FailsBuild || dependency.Baselined {
+			baseline.Entries = append(baseline.Entries, newBaselineEntry(dependency))
+		}
+	}
+
+	sort.Sort(byBaselineImportPath(baseline.Entries))
+
+	return baseline
+}
+
+func newBaselineEntry(dependency Dependency) BaselineEntry {
+	entry := BaselineEntry{
+		ImportPath: dependency.LicensePath,
+		License:    dependency.License,
+		Status:     dependency.Status.String(),
+		SHA256:     []string{},
+	}
+
+	for _, file := range dependency.LicenseFiles {
+		entry.SHA256 = append(entry.SHA256, file.SHA256)
+	}
+
+	return entry
+}
+
+func (e BaselineEntry) matches(other BaselineEntry) bool {
+	if e.ImportPath != other.ImportPath || e.License != other.License || e.Status != other.Status {
+		return false
+	}
+
+	if len(e.SHA256) != len(other.SHA256) {
+		return false
+	}
+
+	for i := range e.SHA256 {
+		if e.SHA256[i] != other.SHA256[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply stops dependencies that match their baseline entry from failing the
+// build. A dependency whose license, status or license files have changed
+// since the baseline was taken still fails. Entries that no longer match
+// anything are listed in StaleBaseline so they can be pruned.
+func (b Baseline) Apply(report Report) Report {
+	used := make([]bool, len(b.Entries))
+
+	dependencies := make([]Dependency, len(report.Dependencies))
+	for i, dependency := range report.Dependencies {
+		if dependency.FailsBuild {
+			current := newBaselineEntry(dependency)
+			for j, entry := range b.Entries {
+				if entry.matches(current) {
+					used[j] = true
+					dependency.FailsBuild = false
+					dependency.Baselined = true
+					break
+				}
+			}
+		}
+		dependencies[i] = dependency
+	}
+
+	applied := NewReport(dependencies)
+	for j, entry := range b.Entries {
+		if !used[j] {
+			applied.StaleBaseline = append(applied.StaleBaseline, entry)
+		}
+	}
+
+	return applied
+}
+
+type byBaselineImportPath []BaselineEntry
+
+func (e byBaselineImportPath) Len() int           { return len(e) }
+func (e byBaselineImportPath) Less(i, j int) bool { return e[i].ImportPath < e[j].ImportPath }
+func (e byBaselineImportPath) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
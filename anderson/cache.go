@@ -0,0 +1,326 @@
+package anderson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cacheVersion is bumped whenever the way licenses are identified or
+// workspaces are loaded changes, so that entries from an older anderson are
+// not trusted.
+const cacheVersion = 2
+
+const cacheFile = "cache.json"
+
+// lockFiles are the files that pin a project's dependencies. They are part of
+// the key that a workspace is cached under.
+var lockFiles = []string{
+	"go.mod",
+	"go.sum",
+	"go.work",
+	"go.work.sum",
+	"vendor/modules.txt",
+	"vendor/vendor.json",
+	"Godeps/Godeps.json",
+	"Gopkg.lock",
+	"glide.lock",
+}
+
+// Cache remembers what previous runs found out so that they don't have to be
+// worked out again. It maps the checksum of a license text to the license it
+// was identified as, and the directory of a project to the workspace that was
+// loaded there along with a checksum of the project's Go files and lock
+// files. A nil Cache is empty and never stores anything.
+type Cache struct {
+	Version    int                        `json:"version"`
+	Licenses   map[string]cachedLicense   `json:"licenses"`
+	Workspaces map[string]cachedWorkspace `json:"workspaces"`
+
+	dir     string
+	mutex   sync.Mutex
+	changed bool
+}
+
+type cachedLicense struct {
+	License string `json:"license"`
+	Match   string `json:"match"`
+}
+
+type cachedWorkspace struct {
+	Key      string     `json:"key"`
+	Packages []*Package `json:"packages"`
+}
+
+// DefaultCacheDir is the directory that the cache is kept in when nothing
+// else is asked for.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "anderson"), nil
+}
+
+// OpenCache reads the cache kept in dir. A cache that is missing, can't be
+// read, is corrupt or was written by a different version of anderson is
+// treated as empty and replaced when the cache is saved.
+func OpenCache(dir string) *Cache {
+	cache := &Cache{dir: dir}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, cacheFile))
+	if err == nil {
+		var stored Cache
+		if json.Unmarshal(data, &stored) == nil && stored.Version == cacheVersion {
+			cache.Licenses = stored.Licenses
+			cache.Workspaces = stored.Workspaces
+		}
+	}
+
+	if cache.Licenses == nil {
+		cache.Licenses = map[string]cachedLicense{}
+	}
+	if cache.Workspaces == nil {
+		cache.Workspaces = map[string]cachedWorkspace{}
+	}
+
+	return cache
+}
+
+// ClearCache removes the cache kept in dir.
+func ClearCache(dir string) error {
+	err := os.Remove(filepath.Join(dir, cacheFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Save writes the cache back to its directory if anything was added to it.
+// The file is replaced in one go so that a run that is interrupted, or two
+// that save at once, can't leave half a cache behind.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.changed {
+		return nil
+	}
+
+	c.Version = cacheVersion
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(c.dir, cacheFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), filepath.Join(c.dir, cacheFile)); err != nil {
+		return err
+	}
+
+	c.changed = false
+
+	return nil
+}
+
+// license looks up the license that a text with the given SHA-256 checksum
+// was identified as.
+func (c *Cache) license(sha256sum string) (Expression, string, bool) {
+	if c == nil {
+		return Expression{}, "", false
+	}
+
+	c.mutex.Lock()
+	cached, ok := c.Licenses[sha256sum]
+	c.mutex.Unlock()
+	if !ok {
+		return Expression{}, "", false
+	}
+
+	expression, err := ParseExpression(cached.License)
+	if err != nil || expression.String() != cached.License {
+		return Expression{}, "", false
+	}
+
+	return expression, cached.Match, true
+}
+
+func (c *Cache) storeLicense(sha256sum string, expression Expression, match string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Licenses[sha256sum] = cachedLicense{License: expression.String(), Match: match}
+	c.changed = true
+}
+
+// LoadWorkspace loads the workspace in the current directory, or takes it
+// from the cache if none of the project's Go files or lock files have changed
+// since it was last loaded. Workspaces are only cached when every package
+// that they depend on is either in the project itself, where changes to it
+// are noticed, or in the module cache, which never changes.
+func (c *Cache) LoadWorkspace() (Workspace, error) {
+	if c == nil {
+		return LoadWorkspace()
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return LoadWorkspace()
+	}
+
+	key, err := workspaceKey(dir)
+	if err != nil {
+		return LoadWorkspace()
+	}
+
+	c.mutex.Lock()
+	cached, ok := c.Workspaces[dir]
+	c.mutex.Unlock()
+	if ok && cached.Key == key {
+		return Workspace{Packages: cached.Packages}, nil
+	}
+
+	workspace, err := LoadWorkspace()
+	if err != nil {
+		return workspace, err
+	}
+
+	var packages []*Package
+	for _, pkg := range workspace.Packages {
+		if pkg.Standard {
+			continue
+		}
+		if !isImmutablePackage(pkg, dir) {
+			return workspace, nil
+		}
+		packages = append(packages, pkg)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Workspaces[dir] = cachedWorkspace{Key: key, Packages: packages}
+	c.changed = true
+
+	return workspace, nil
+}
+
+// isImmutablePackage reports whether a package can only change along with
+// the checksum of the project in dir.
+func isImmutablePackage(pkg *Package, dir string) bool {
+	if pkg.Dir != "" && pathIsWithin(pkg.Dir, dir) {
+		return true
+	}
+
+	if pkg.Module == nil || pkg.Module.Main {
+		return false
+	}
+
+	return pkg.Module.Replace == nil || pkg.Module.Replace.Version != ""
+}
+
+// workspaceKey is a checksum of everything that decides which packages the
+// project in dir is made of: the environment that go list runs in, every Go
+// file below dir and the lock files in dir and its parents, since the module
+// that dir is in may start further up.
+func workspaceKey(dir string) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "anderson cache %d\n", cacheVersion)
+	for _, name := range []string{"GOFLAGS", "GO111MODULE", "GOOS", "GOARCH", "CGO_ENABLED", "GOPATH", "GOWORK"} {
+		fmt.Fprintf(hash, "%s=%s\n", name, os.Getenv(name))
+	}
+
+	var files []string
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		for _, name := range lockFiles {
+			path := filepath.Join(parent, filepath.FromSlash(name))
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files = append(files, path)
+			}
+		}
+
+		if parent == filepath.Dir(parent) {
+			break
+		}
+	}
+
+	var found []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if filepath.Ext(path) == ".go" || contains(lockFiles, filepath.ToSlash(rel)) {
+			found = append(found, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(found)
+	for _, path := range append(files, found...) {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(hash, "%s\n", path)
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -1,8 +1,14 @@
 package anderson
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/ryanuber/go-license"
@@ -12,68 +18,505 @@ const (
 	maxParentHops = 10
 )
 
+var unknownLicense = NewLicenseExpression("Unknown")
+
+var spdxIdentifier = regexp.MustCompile(`(?m)SPDX-License-Identifier:\s*(.+?)\s*(\*/)?$`)
+
+// LicenseClassifier decides the status of a dependency's license. Scope is
+// the scope of the dependency being classified and selects which of the
+// per-scope lists in Config apply. Version is its version, if it has one,
+// for the overrides that only apply at a version. License texts that are in
+// Cache are not identified again.
 type LicenseClassifier struct {
-	Config Config
+	Config  Config
+	Scope   Scope
+	Version string
+	Cache   *Cache
 }
 
-func (c LicenseClassifier) Classify(path string, importPath string) (LicenseStatus, string, string, error) {
+// Classification is the outcome of looking for a dependency's license.
+// License is everything that was found and Elected is the part of it that
+// we take the dependency under when it offers a choice. Override is set when
+// the license was asserted in the config instead of found. Nested is the
+// license files below Path that a deep scan found a different license in.
+// Trace describes each step that was taken to get there.
+type Classification struct {
+	Status   LicenseStatus
+	Path     string
+	License  Expression
+	Elected  Expression
+	Rule     *Rule
+	Files    []LicenseFile
+	Nested   []LicenseFile
+	Override *Override
+	Trace    []string
+}
+
+// LicenseFile is a file that a license was read from along with checksums
+// of its contents. Match describes how the license was recognised. Header is
+// set when the file is a Go file whose header states the license.
+type LicenseFile struct {
+	Path    string     `json:"path"`
+	License Expression `json:"license"`
+	Match   string     `json:"match"`
+	SHA1    string     `json:"sha1"`
+	SHA256  string     `json:"sha256"`
+	Header  bool       `json:"header,omitempty"`
+}
+
+// Rule is the configuration entry that decided a dependency's status. List
+// is the name of the section in .anderson.yml that the entry is from. Rules
+// from the exceptions also carry the whole exception.
+type Rule struct {
+	List      string     `json:"list"`
+	Entry     string     `json:"entry"`
+	Exception *Exception `json:"exception,omitempty"`
+}
+
+// Classify looks for a license in path and then in its parents, stopping once
+// it leaves root. If there are no license files at all the headers of the Go
+// files in path are read instead. Dependencies with an override take the
+// license that it asserts without looking. Exceptions for a dependency
+// without a license are only applied once nothing was found.
+func (c LicenseClassifier) Classify(path string, root string, importPath string) (Classification, error) {
+	if key, override, ok := c.Config.override(importPath, c.Version); ok {
+		overridePath, _ := splitOverrideKey(key)
+		return c.classifyOverride(overrideDir(path, root, importPath, overridePath), importPath, key, override), nil
+	}
+
+	var trace []string
 	for hops := 0; hops < maxParentHops; hops++ {
 		newPath := c.parentPath(path, hops)
-		if c.pathIsAGopath(newPath) {
+		if !pathIsWithin(newPath, root) {
+			trace = append(trace, fmt.Sprintf("stopped at %s because it is outside %s", newPath, root))
 			break
 		}
 
-		status, licenseType, err := c.classifyPath(newPath, importPath)
+		classification, err := c.classifyPath(newPath, importPath)
+		classification.Trace = append(trace, classification.Trace...)
+
+		if classification.Status != LicenseTypeNoLicense {
+			return classification, err
+		}
+
+		trace = classification.Trace
+	}
 
-		if status != LicenseTypeNoLicense {
-			return status, newPath, licenseType, err
+	if expression, files, err := identifyLicenseHeaders(path); err == nil && len(files) > 0 {
+		trace = append(trace, fmt.Sprintf("found license headers in the Go files in %s", path))
+		for _, file := range files {
+			trace = append(trace, fmt.Sprintf("%s is %s: %s", filepath.Base(file.Path), file.License, file.Match))
 		}
+		if len(files) > 1 {
+			trace = append(trace, fmt.Sprintf("together the license is %s", expression))
+		}
+
+		return c.judge(path, importPath, expression, files, trace), nil
+	}
+
+	status := LicenseTypeNoLicense
+	var rule *Rule
+	if excepted, exceptionRule, steps, ok := c.applyException(importPath, unknownLicense, nil); ok {
+		status, rule = excepted, exceptionRule
+		trace = append(trace, steps...)
 	}
 
-	return LicenseTypeNoLicense, path, "Unknown", nil
+	return Classification{
+		Status:  status,
+		Path:    path,
+		License: unknownLicense,
+		Elected: unknownLicense,
+		Rule:    rule,
+		Trace:   append(trace, fmt.Sprintf("status is %s", status.Message())),
+	}, nil
 }
 
-func (c LicenseClassifier) classifyPath(path string, importPath string) (LicenseStatus, string, error) {
-	l, err := license.NewFromDir(path)
+func (c LicenseClassifier) classifyPath(path string, importPath string) (Classification, error) {
+	expression, files, err := c.identifyLicenses(path)
 
 	if err != nil {
 		switch err.Error() {
 		case license.ErrNoLicenseFile:
-			if contains(c.Config.Exceptions, importPath) {
-				return LicenseTypeAllowed, "Unknown", nil
+			return Classification{
+				Status:  LicenseTypeNoLicense,
+				Path:    path,
+				License: unknownLicense,
+				Elected: unknownLicense,
+				Trace:   []string{fmt.Sprintf("no license files in %s", path)},
+			}, nil
+		default:
+			return Classification{
+				Status:  LicenseTypeUnknown,
+				Path:    path,
+				License: NewLicenseExpression("Error"),
+				Elected: NewLicenseExpression("Error"),
+				Trace: []string{
+					fmt.Sprintf("could not read the license files in %s: %s", path, err),
+					fmt.Sprintf("status is %s", LicenseTypeUnknown.Message()),
+				},
+			}, fmt.Errorf("Could not determine license for: %s", importPath)
+		}
+	}
+
+	trace := []string{fmt.Sprintf("found license files in %s", path)}
+	for _, file := range files {
+		name, _ := filepath.Rel(path, file.Path)
+		trace = append(trace, fmt.Sprintf("%s is %s: %s", name, file.License, file.Match))
+	}
+	if len(files) > 1 {
+		trace = append(trace, fmt.Sprintf("together the license is %s", expression))
+	}
+
+	return c.judge(path, importPath, expression, files, trace), nil
+}
+
+// classifyOverride takes the license that an override asserts for a
+// dependency and judges it like one that was found.
+func (c LicenseClassifier) classifyOverride(path string, importPath string, key string, override Override) Classification {
+	expression, err := ParseExpression(override.License)
+	if err != nil {
+		expression = NewLicenseExpression(override.License)
+	}
+
+	trace := []string{fmt.Sprintf("%s is asserted to be %s by overrides entry %q", importPath, expression, key)}
+	if override.Reference != "" {
+		trace = append(trace, fmt.Sprintf("the reference given is: %s", override.Reference))
+	}
+
+	classification := c.judge(path, importPath, expression, nil, trace)
+	classification.Override = &override
+
+	return classification
+}
+
+// judge applies the config to the license that was found in path.
+func (c LicenseClassifier) judge(path string, importPath string, expression Expression, files []LicenseFile, trace []string) Classification {
+	status, elected, rule := c.evaluate(expression)
+	trace = append(trace, c.explain(expression, elected)...)
+
+	if status != LicenseTypeBanned && status != LicenseTypeAllowed {
+		if excepted, exceptionRule, steps, ok := c.applyException(importPath, expression, files); ok {
+			status, rule = excepted, exceptionRule
+			trace = append(trace, steps...)
+		}
+	}
+
+	trace = append(trace, fmt.Sprintf("status is %s", status.Message()))
+
+	return Classification{
+		Status:  status,
+		Path:    path,
+		License: expression,
+		Elected: elected,
+		Rule:    rule,
+		Files:   files,
+		Trace:   trace,
+	}
+}
+
+// explain describes which configuration entry applies to each license in an
+// expression and which of them was elected.
+func (c LicenseClassifier) explain(expression Expression, elected Expression) []string {
+	var trace []string
+	for _, name := range expression.Licenses() {
+		status, rule := c.licenseStatus(name)
+		switch {
+		case rule != nil:
+			trace = append(trace, fmt.Sprintf("%s matches %s entry %q so it is %s", name, rule.List, rule.Entry, status.Message()))
+		case status == LicenseTypeUnknown:
+			trace = append(trace, fmt.Sprintf("%s could not be recognised so it is %s", name, status.Message()))
+		default:
+			trace = append(trace, fmt.Sprintf("%s is in neither the whitelist nor the blacklist so it is %s", name, status.Message()))
+		}
+	}
+
+	if elected.String() != expression.String() {
+		trace = append(trace, fmt.Sprintf("elected %s from %s", elected, expression))
+	}
+
+	return trace
+}
+
+// evaluate applies the whitelist and blacklist to a license expression. All
+// of the licenses joined by AND apply so the worst of them decides, while
+// licenses joined by OR are alternatives so the best of them is elected.
+// Equally good alternatives are decided by the order of the prefer list.
+func (c LicenseClassifier) evaluate(expression Expression) (LicenseStatus, Expression, *Rule) {
+	if expression.IsLicense() {
+		status, rule := c.licenseStatus(expression.License)
+		return status, expression, rule
+	}
+
+	statuses := make([]LicenseStatus, len(expression.Operands))
+	elected := make([]Expression, len(expression.Operands))
+	rules := make([]*Rule, len(expression.Operands))
+	for i, operand := range expression.Operands {
+		statuses[i], elected[i], rules[i] = c.evaluate(operand)
+	}
+
+	if expression.Operator == ExpressionAnd {
+		worst := 0
+		for i := 1; i < len(statuses); i++ {
+			if statuses[i].severity() > statuses[worst].severity() {
+				worst = i
+			}
+		}
+
+		return statuses[worst], CombineExpressions(ExpressionAnd, elected...), rules[worst]
+	}
+
+	best := 0
+	for i := 1; i < len(statuses); i++ {
+		if statuses[i].severity() < statuses[best].severity() {
+			best = i
+			continue
+		}
+
+		if statuses[i].severity() == statuses[best].severity() && c.preference(elected[i]) < c.preference(elected[best]) {
+			best = i
+		}
+	}
+
+	return statuses[best], elected[best], rules[best]
+}
+
+// exception finds the first exception that covers a package with a license.
+func (c LicenseClassifier) exception(importPath string, license Expression) (Exception, bool) {
+	for _, exception := range c.Config.Exceptions {
+		if exception.Matches(importPath, license) {
+			return exception, true
+		}
+	}
+	return Exception{}, false
+}
+
+// applyException allows a package that an exception covers, unless the
+// exception is pinned to license files that have since changed, in which
+// case the package needs to be reviewed again.
+func (c LicenseClassifier) applyException(importPath string, license Expression, files []LicenseFile) (LicenseStatus, *Rule, []string, bool) {
+	exception, ok := c.exception(importPath, license)
+	if !ok {
+		return LicenseTypeUnknown, nil, nil, false
+	}
+
+	rule := &Rule{List: "exceptions", Entry: exception.Path, Exception: &exception}
+	trace := explainException(importPath, exception)
+
+	if !exception.MatchesFiles(files) {
+		trace = append(trace, "the license files are not the ones that the exception was approved for")
+		for _, file := range files {
+			if !contains(exception.SHA256, file.SHA256) {
+				trace = append(trace, fmt.Sprintf("%s has changed and needs to be reviewed again, its sha256 is now %s", filepath.Base(file.Path), file.SHA256))
+			}
+		}
+		return LicenseTypeChanged, rule, trace, true
+	}
+
+	return LicenseTypeAllowed, rule, trace, true
+}
+
+func explainException(importPath string, exception Exception) []string {
+	trace := []string{fmt.Sprintf("%s is in the exceptions", importPath)}
+	if exception.Path != importPath {
+		trace[0] = fmt.Sprintf("%s matches exceptions entry %q", importPath, exception.Path)
+	}
+
+	if approval := exception.Approval(); approval != "" {
+		trace = append(trace, "the exception is "+approval)
+	}
+	if exception.Reason != "" {
+		trace = append(trace, "the reason given is: "+exception.Reason)
+	}
+
+	return trace
+}
+
+func (c LicenseClassifier) licenseStatus(name string) (LicenseStatus, *Rule) {
+	if name == unknownLicense.License {
+		return LicenseTypeUnknown, nil
+	}
+
+	if policy, ok := c.Config.Scopes[c.Scope]; ok {
+		list := fmt.Sprintf("scopes.%s.", c.Scope)
+
+		if entry, ok := c.listed(policy.Blacklist, name); ok {
+			return LicenseTypeBanned, &Rule{List: list + "blacklist", Entry: entry}
+		}
+
+		if entry, ok := c.listed(policy.Whitelist, name); ok {
+			return LicenseTypeAllowed, &Rule{List: list + "whitelist", Entry: entry}
+		}
+	}
+
+	if entry, ok := c.listed(c.Config.Blacklist, name); ok {
+		return LicenseTypeBanned, &Rule{List: "blacklist", Entry: entry}
+	}
+
+	if entry, ok := c.listed(c.Config.Whitelist, name); ok {
+		return LicenseTypeAllowed, &Rule{List: "whitelist", Entry: entry}
+	}
+
+	return LicenseTypeMarginal, nil
+}
+
+// listed checks for a license in one of the configured lists and returns the
+// entry that matched. A license with an exception or an "or later" suffix is
+// also found under its plain name.
+func (c LicenseClassifier) listed(list []string, name string) (string, bool) {
+	if contains(list, name) {
+		return name, true
+	}
+
+	base := strings.SplitN(name, " WITH ", 2)[0]
+	base = strings.TrimSuffix(base, "+")
+
+	return base, contains(list, base)
+}
+
+func (c LicenseClassifier) preference(expression Expression) int {
+	preference := len(c.Config.Prefer)
+	for _, name := range expression.Licenses() {
+		for i, preferred := range c.Config.Prefer {
+			if preferred == name && i < preference {
+				preference = i
 			}
+		}
+	}
+	return preference
+}
+
+// identifyLicenses works out the licenses of every license file in a
+// directory. Files named after a license, like LICENSE-MIT, MIT-LICENSE and
+// LICENSE.apache2, offer a choice of licenses and are joined with OR. Any
+// other license files, such as LICENSE.txt and COPYING or the files in a
+// REUSE LICENSES directory, all apply and are joined with AND. NOTICE files
+// are only counted when their text is a license.
+func (c LicenseClassifier) identifyLicenses(path string) (Expression, []LicenseFile, error) {
+	found, err := licenseFiles(path, c.Config.ExtraLicenseFiles)
+	if err != nil {
+		return Expression{}, nil, err
+	}
+
+	var files []LicenseFile
+	var alternatives, conjuncts []Expression
+	for _, candidate := range found {
+		file, err := identifyLicenseFile(filepath.Join(path, filepath.FromSlash(candidate.name)), c.Cache)
+		if err != nil {
+			return Expression{}, nil, err
+		}
 
-			return LicenseTypeNoLicense, "Unknown", nil
-		case license.ErrUnrecognizedLicense:
-			if contains(c.Config.Exceptions, importPath) {
-				return LicenseTypeAllowed, "Unknown", nil
+		switch candidate.kind {
+		case reuseLicenseFile:
+			if expression, ok := reuseLicense(candidate.name); ok {
+				file.License = expression
+				file.Match = fmt.Sprintf("it is named %s in the REUSE LICENSES directory", filepath.Base(candidate.name))
 			}
+		case noticeLicenseFile:
+			if file.License.String() == unknownLicense.String() {
+				continue
+			}
+		}
 
-			return LicenseTypeUnknown, "Unknown", nil
-		default:
-			return LicenseTypeUnknown, "Error", fmt.Errorf("Could not determine license for: %s", importPath)
+		files = append(files, file)
+		if candidate.kind == alternativeLicenseFile {
+			alternatives = append(alternatives, file.License)
+		} else {
+			conjuncts = append(conjuncts, file.License)
 		}
 	}
 
-	if contains(c.Config.Blacklist, l.Type) {
-		return LicenseTypeBanned, l.Type, nil
+	if len(files) == 0 {
+		return Expression{}, nil, errors.New(license.ErrNoLicenseFile)
 	}
 
-	if contains(c.Config.Whitelist, l.Type) {
-		return LicenseTypeAllowed, l.Type, nil
+	if len(alternatives) > 0 {
+		conjuncts = append(conjuncts, CombineExpressions(ExpressionOr, alternatives...))
 	}
 
-	if contains(c.Config.Exceptions, importPath) {
-		return LicenseTypeAllowed, l.Type, nil
+	return CombineExpressions(ExpressionAnd, conjuncts...), files, nil
+}
+
+// identifyLicenseFile works out the license of a single file with
+// identifyLicenseText. Texts that have been seen before are looked up in the
+// cache instead.
+func identifyLicenseFile(path string, cache *Cache) (LicenseFile, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return LicenseFile{}, err
+	}
+
+	sha1sum := sha1.Sum(text)
+	sha256sum := sha256.Sum256(text)
+	file := LicenseFile{
+		Path:   path,
+		SHA1:   hex.EncodeToString(sha1sum[:]),
+		SHA256: hex.EncodeToString(sha256sum[:]),
 	}
 
-	return LicenseTypeMarginal, l.Type, nil
+	var ok bool
+	file.License, file.Match, ok = cache.license(file.SHA256)
+	if !ok {
+		file.License, file.Match = identifyLicenseText(text)
+		cache.storeLicense(file.SHA256, file.License, file.Match)
+	}
+
+	return file, nil
 }
 
-func (c LicenseClassifier) pathIsAGopath(path string) bool {
-	paths, _ := Gopaths()
+// identifyLicenseText returns the license of a text along with a description
+// of how it was recognised. The key phrases that go-license looks for are
+// shared by many licenses, so they are only believed when the closest SPDX
+// template is the same license. Otherwise the template decides.
+func identifyLicenseText(text []byte) (Expression, string) {
+	if match := spdxIdentifier.FindSubmatch(text); match != nil {
+		if expression, err := ParseExpression(string(match[1])); err == nil {
+			return expression, fmt.Sprintf("SPDX-License-Identifier: %s", match[1])
+		}
+	}
+
+	l := license.New("", string(text))
+	guessed := l.GuessType() == nil
+
+	matches := MatchLicenseTemplates(l.Text)
+	if len(matches) == 0 {
+		if guessed {
+			return NewLicenseExpression(l.Type), fmt.Sprintf("contains the key phrases of %s", l.Type)
+		}
+		return unknownLicense, "no key phrases or SPDX templates match"
+	}
+	match := matches[0]
+	name := templateLicenseName(match.Name)
+
+	if guessed && l.Type == name {
+		return NewLicenseExpression(l.Type), fmt.Sprintf("contains the key phrases of %s", l.Type)
+	}
 
-	return contains(paths, path)
+	if match.Confidence >= MinimumMatchConfidence {
+		description := fmt.Sprintf("matches the SPDX template for %s with %.0f%% confidence", match.Name, match.Confidence*100)
+		if guessed {
+			description += fmt.Sprintf(", although it contains the key phrases of %s", l.Type)
+		}
+		return NewLicenseExpression(name), description
+	}
+
+	if guessed {
+		return unknownLicense, fmt.Sprintf("contains the key phrases of %s but the closest SPDX template is %s with only %.0f%% confidence", l.Type, match.Name, match.Confidence*100)
+	}
+
+	return unknownLicense, fmt.Sprintf("closest SPDX template is %s with only %.0f%% confidence, %.0f%% is needed", match.Name, match.Confidence*100, MinimumMatchConfidence*100)
+}
+
+// templateLicenseName is the name that a license matched by its SPDX
+// template is reported by. The BSD licenses keep the names that go-license
+// gives them so that a license has the same name however it was recognised.
+func templateLicenseName(template string) string {
+	switch template {
+	case "BSD-3-Clause":
+		return license.LicenseNewBSD
+	case "BSD-2-Clause":
+		return license.LicenseFreeBSD
+	}
+	return template
 }
 
 func (c LicenseClassifier) parentPath(path string, hops int) string {
@@ -1,7 +1,262 @@
 package anderson
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// PolicyDirEnv names the environment variable with the directory that shared
+// policies are looked up in.
+const PolicyDirEnv = "ANDERSON_POLICY_DIR"
+
 type Config struct {
-	Whitelist  []string `yaml:"whitelist"`
-	Blacklist  []string `yaml:"blacklist"`
-	Exceptions []string `yaml:"exceptions"`
+	Extends           PolicyPaths           `yaml:"extends,omitempty"`
+	Whitelist         []string              `yaml:"whitelist,omitempty"`
+	Blacklist         []string              `yaml:"blacklist,omitempty"`
+	Exceptions        []Exception           `yaml:"exceptions,omitempty"`
+	Prefer            []string              `yaml:"prefer,omitempty"`
+	Overrides         map[string]Override   `yaml:"overrides,omitempty"`
+	Scopes            map[Scope]ScopePolicy `yaml:"scopes,omitempty"`
+	ExtraLicenseFiles []string              `yaml:"extra_license_files,omitempty"`
+}
+
+// ScopePolicy is a whitelist and blacklist that only apply to the
+// dependencies in one scope. They are checked before the main lists so that,
+// for example, test dependencies can be allowed licenses that could never
+// ship.
+type ScopePolicy struct {
+	Whitelist []string `yaml:"whitelist,omitempty"`
+	Blacklist []string `yaml:"blacklist,omitempty"`
+}
+
+// PolicyPaths are the shared policy files that a config extends. A single
+// path can be given on its own instead of as a list.
+type PolicyPaths []string
+
+func (p *PolicyPaths) UnmarshalYAML(tag string, value interface{}) error {
+	if pointer, ok := value.(*interface{}); ok {
+		value = *pointer
+	}
+
+	switch value.(type) {
+	case string, []interface{}:
+		*p = yamlList(value)
+		return nil
+	default:
+		return fmt.Errorf("extends must be a path or a list of paths, not %v", value)
+	}
+}
+
+// LoadConfig reads a config file and the policies it extends and merges them
+// into a single config.
+//
+// The policies are merged in the order they are listed, each after its own
+// policies, and then the file itself is applied on top. Blacklists and
+// exceptions from every file apply. A file can narrow down the whitelists it
+// inherits but it cannot allow a license that they do not, or assert one
+// with an override, since loosening a shared policy has to be done with
+// exceptions.
+func LoadConfig(path string) (Config, error) {
+	return loadConfig(path, nil)
+}
+
+func loadConfig(path string, extending []string) (Config, error) {
+	var config Config
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return config, err
+	}
+
+	if contains(extending, absPath) {
+		return config, fmt.Errorf("%s extends itself through %s", path, strings.Join(extending, ", "))
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	if err := candiedyaml.NewDecoder(file).Decode(&config); err != nil {
+		return config, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if len(config.Extends) == 0 {
+		return config, nil
+	}
+
+	var inherited Config
+	for i, policy := range config.Extends {
+		policyConfig, err := loadConfig(policyPath(filepath.Dir(absPath), policy), append(extending, absPath))
+		if err != nil {
+			return config, err
+		}
+
+		if i == 0 {
+			inherited = policyConfig
+		} else {
+			inherited = inherited.combine(policyConfig)
+		}
+	}
+
+	effective, err := inherited.extendWith(config)
+	if err != nil {
+		return config, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return effective, nil
+}
+
+// policyPath finds a policy that a config in dir extends. Paths are relative
+// to dir unless the policy directory is set, in which case anything that
+// does not start with ./ or ../ is looked up there instead.
+func policyPath(dir string, policy string) string {
+	if filepath.IsAbs(policy) {
+		return policy
+	}
+
+	relative := strings.HasPrefix(policy, "./") || strings.HasPrefix(policy, "../")
+	if policyDir := os.Getenv(PolicyDirEnv); policyDir != "" && !relative {
+		return filepath.Join(policyDir, policy)
+	}
+
+	return filepath.Join(dir, policy)
+}
+
+// combine merges two policies that are extended side by side. Everything
+// that either of them allows or bans is allowed or banned, and other's
+// overrides win over c's for the same dependency.
+func (c Config) combine(other Config) Config {
+	combined := Config{
+		Whitelist:  union(c.Whitelist, other.Whitelist),
+		Blacklist:  union(c.Blacklist, other.Blacklist),
+		Exceptions: append(append([]Exception{}, c.Exceptions...), other.Exceptions...),
+		Prefer:     union(c.Prefer, other.Prefer),
+		Overrides:  map[string]Override{},
+		Scopes:     map[Scope]ScopePolicy{},
+
+		ExtraLicenseFiles: union(c.ExtraLicenseFiles, other.ExtraLicenseFiles),
+	}
+
+	for key, override := range c.Overrides {
+		combined.Overrides[key] = override
+	}
+
+	for key, override := range other.Overrides {
+		combined.Overrides[key] = override
+	}
+
+	for scope, policy := range c.Scopes {
+		combined.Scopes[scope] = policy
+	}
+
+	for scope, policy := range other.Scopes {
+		existing := combined.Scopes[scope]
+		combined.Scopes[scope] = ScopePolicy{
+			Whitelist: union(existing.Whitelist, policy.Whitelist),
+			Blacklist: union(existing.Blacklist, policy.Blacklist),
+		}
+	}
+
+	return combined
+}
+
+// extendWith applies a config on top of the policy that it extends. Its
+// blacklists and exceptions are added to the policy's and its whitelists
+// replace the policy's, as long as the policy already allows every license in
+// them. Its blacklist also takes the licenses in it out of the whitelists of
+// the policy's scopes, so that they are banned in every scope. It can't have
+// overrides, since asserting a license the policy allows would let a
+// dependency through without an exception.
+func (c Config) extendWith(config Config) (Config, error) {
+	effective := c.combine(Config{
+		Blacklist:  config.Blacklist,
+		Exceptions: config.Exceptions,
+
+		ExtraLicenseFiles: config.ExtraLicenseFiles,
+	})
+	effective.Prefer = union(config.Prefer, c.Prefer)
+
+	if len(config.Overrides) > 0 {
+		var keys []string
+		for key := range config.Overrides {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return effective, fmt.Errorf("overrides entry %q can't be added to the policy that is extended, add an exception for the dependency instead", keys[0])
+	}
+
+	for scope, policy := range effective.Scopes {
+		policy.Whitelist = without(policy.Whitelist, config.Blacklist)
+		effective.Scopes[scope] = policy
+	}
+
+	if config.Whitelist != nil {
+		if err := c.checkAllowed(config.Whitelist, "", "whitelist"); err != nil {
+			return effective, err
+		}
+		effective.Whitelist = config.Whitelist
+	}
+
+	for scope, policy := range config.Scopes {
+		scoped := effective.Scopes[scope]
+		scoped.Blacklist = union(scoped.Blacklist, policy.Blacklist)
+
+		if policy.Whitelist != nil {
+			if err := c.checkAllowed(policy.Whitelist, scope, fmt.Sprintf("scopes.%s.whitelist", scope)); err != nil {
+				return effective, err
+			}
+			scoped.Whitelist = policy.Whitelist
+		}
+
+		effective.Scopes[scope] = scoped
+	}
+
+	return effective, nil
+}
+
+// checkAllowed makes sure that every license in a whitelist is already
+// allowed in a scope.
+func (c Config) checkAllowed(whitelist []string, scope Scope, list string) error {
+	classifier := LicenseClassifier{Config: c, Scope: scope}
+	for _, license := range whitelist {
+		if status, _ := classifier.licenseStatus(license); status != LicenseTypeAllowed {
+			return fmt.Errorf("%s entry %q is %s under the policy that is extended, add exceptions for the dependencies that need it instead", list, license, status.Message())
+		}
+	}
+	return nil
+}
+
+// Write writes the config as YAML.
+func (c Config) Write(w io.Writer) error {
+	return candiedyaml.NewEncoder(w).Encode(c)
+}
+
+// union appends the entries of b that are not already in a.
+func union(a []string, b []string) []string {
+	result := append([]string{}, a...)
+	for _, entry := range b {
+		if !contains(result, entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// without returns the entries of a that are not in b.
+func without(a []string, b []string) []string {
+	var result []string
+	for _, entry := range a {
+		if !contains(b, entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
 }
@@ -0,0 +1,360 @@
+package anderson
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	cycloneDXSpecVersion = "1.5"
+	cycloneDXNamespace   = "http://cyclonedx.org/schema/bom/1.5"
+)
+
+// CycloneDXBOM is a CycloneDX 1.5 bill of materials. It can be written as
+// either JSON or XML.
+type CycloneDXBOM struct {
+	XMLName      xml.Name              `json:"-" xml:"bom"`
+	XMLNS        string                `json:"-" xml:"xmlns,attr"`
+	BOMFormat    string                `json:"bomFormat" xml:"-"`
+	SpecVersion  string                `json:"specVersion" xml:"-"`
+	SerialNumber string                `json:"serialNumber" xml:"serialNumber,attr"`
+	Version      int                   `json:"version" xml:"version,attr"`
+	Metadata     CycloneDXMetadata     `json:"metadata" xml:"metadata"`
+	Components   []CycloneDXComponent  `json:"components" xml:"components>component"`
+	Dependencies []CycloneDXDependency `json:"dependencies" xml:"dependencies>dependency"`
+}
+
+type CycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp" xml:"timestamp"`
+	Tools     CycloneDXTools     `json:"tools" xml:"tools"`
+	Component CycloneDXComponent `json:"component" xml:"component"`
+}
+
+type CycloneDXTools struct {
+	Components []CycloneDXComponent `json:"components" xml:"components>component"`
+}
+
+type CycloneDXComponent struct {
+	Type       string              `json:"type" xml:"type,attr"`
+	BOMRef     string              `json:"bom-ref,omitempty" xml:"bom-ref,attr,omitempty"`
+	Name       string              `json:"name" xml:"name"`
+	Version    string              `json:"version,omitempty" xml:"version,omitempty"`
+	Scope      string              `json:"scope,omitempty" xml:"scope,omitempty"`
+	Licenses   CycloneDXLicenses   `json:"licenses,omitempty" xml:"licenses,omitempty"`
+	PURL       string              `json:"purl,omitempty" xml:"purl,omitempty"`
+	Properties []CycloneDXProperty `json:"properties,omitempty" xml:"properties>property,omitempty"`
+	Evidence   *CycloneDXEvidence  `json:"evidence,omitempty" xml:"evidence,omitempty"`
+}
+
+// CycloneDXProperty is a name-value pair for information that CycloneDX has
+// no field of its own for.
+type CycloneDXProperty struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Value string `json:"value" xml:",chardata"`
+}
+
+// CycloneDXLicenses is either a list of licenses or a single license
+// expression.
+type CycloneDXLicenses []CycloneDXLicenseChoice
+
+type CycloneDXLicenseChoice struct {
+	License    *CycloneDXLicense `json:"license,omitempty"`
+	Expression string            `json:"expression,omitempty"`
+}
+
+type CycloneDXLicense struct {
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+}
+
+type CycloneDXEvidence struct {
+	Occurrences []CycloneDXOccurrence `json:"occurrences,omitempty" xml:"occurrences>occurrence,omitempty"`
+	Licenses    CycloneDXLicenses     `json:"licenses,omitempty" xml:"licenses,omitempty"`
+}
+
+type CycloneDXOccurrence struct {
+	Location string `json:"location" xml:"location"`
+}
+
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// NewCycloneDXBOM describes a report as a CycloneDX BOM. The dependency graph
+// is worked out from the import graph by mapping every package on to the
+// component whose license covers it. Without an import graph the root simply
+// depends on every component.
+func NewCycloneDXBOM(rootName string, report Report, graph ImportGraph, created time.Time) CycloneDXBOM {
+	root := CycloneDXComponent{
+		Type:   "application",
+		BOMRef: PackageURL(rootName, ""),
+		Name:   rootName,
+		PURL:   PackageURL(rootName, ""),
+	}
+
+	bom := CycloneDXBOM{
+		XMLNS:        cycloneDXNamespace,
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + randomUUID(),
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: CycloneDXTools{
+				Components: []CycloneDXComponent{{Type: "application", Name: "anderson"}},
+			},
+			Component: root,
+		},
+		Components: []CycloneDXComponent{},
+	}
+
+	for _, dependency := range report.Dependencies {
+		component := CycloneDXComponent{
+			Type:     "library",
+			BOMRef:   PackageURL(dependency.LicensePath, dependency.Version),
+			Name:     dependency.LicensePath,
+			Version:  dependency.Version,
+			Scope:    cycloneDXScope(dependency.Scope),
+			Licenses: cycloneDXLicenses(dependency.License),
+			PURL:     PackageURL(dependency.LicensePath, dependency.Version),
+			Properties: []CycloneDXProperty{
+				{Name: "anderson:scope", Value: string(dependency.Scope)},
+			},
+		}
+
+		if dependency.Asserted {
+			component.Properties = append(component.Properties, CycloneDXProperty{Name: "anderson:license-asserted", Value: "true"})
+			if dependency.Override != nil && dependency.Override.Reference != "" {
+				component.Properties = append(component.Properties, CycloneDXProperty{Name: "anderson:license-reference", Value: dependency.Override.Reference})
+			}
+		}
+
+		if len(dependency.LicenseFiles) > 0 {
+			evidence := &CycloneDXEvidence{}
+			var found []Expression
+			for _, file := range dependency.LicenseFiles {
+				evidence.Occurrences = append(evidence.Occurrences, CycloneDXOccurrence{
+					Location: path.Join(dependency.LicensePath, dependency.LicenseFileName(file)),
+				})
+				found = append(found, file.License)
+			}
+			evidence.Licenses = cycloneDXEvidenceLicenses(found)
+			component.Evidence = evidence
+		}
+
+		bom.Components = append(bom.Components, component)
+	}
+
+	bom.Dependencies = cycloneDXDependencies(root, bom.Components, graph)
+
+	return bom
+}
+
+func cycloneDXDependencies(root CycloneDXComponent, components []CycloneDXComponent, graph ImportGraph) []CycloneDXDependency {
+	dependsOn := map[string][]string{root.BOMRef: {}}
+	for _, component := range components {
+		dependsOn[component.BOMRef] = []string{}
+	}
+
+	if len(graph.Imports) == 0 {
+		for _, component := range components {
+			dependsOn[root.BOMRef] = append(dependsOn[root.BOMRef], component.BOMRef)
+		}
+	}
+
+	componentRef := func(importPath string) string {
+		if contains(graph.Roots, importPath) {
+			return root.BOMRef
+		}
+
+		ref, longest := "", -1
+		for _, component := range components {
+			if importPath == component.Name || strings.HasPrefix(importPath, component.Name+"/") {
+				if len(component.Name) > longest {
+					ref, longest = component.BOMRef, len(component.Name)
+				}
+			}
+		}
+		return ref
+	}
+
+	for importPath, imports := range graph.Imports {
+		from := componentRef(importPath)
+		if from == "" {
+			continue
+		}
+
+		for _, imported := range imports {
+			to := componentRef(imported)
+			if to == "" || to == from || contains(dependsOn[from], to) {
+				continue
+			}
+			dependsOn[from] = append(dependsOn[from], to)
+		}
+	}
+
+	refs := []string{root.BOMRef}
+	for _, component := range components {
+		refs = append(refs, component.BOMRef)
+	}
+
+	dependencies := []CycloneDXDependency{}
+	for _, ref := range refs {
+		sort.Strings(dependsOn[ref])
+		dependencies = append(dependencies, CycloneDXDependency{Ref: ref, DependsOn: dependsOn[ref]})
+	}
+
+	return dependencies
+}
+
+// cycloneDXScope maps a dependency's scope onto CycloneDX's, where test and
+// tool dependencies are excluded from what ships.
+func cycloneDXScope(scope Scope) string {
+	if scope.Ships() {
+		return "required"
+	}
+	return "excluded"
+}
+
+// cycloneDXLicenses describes a license expression in CycloneDX terms. Single
+// licenses use their SPDX identifier, or their name if they do not have one,
+// while anything more complicated is given as an SPDX expression.
+func cycloneDXLicenses(license string) CycloneDXLicenses {
+	expression, err := ParseExpression(license)
+	if err != nil {
+		return nil
+	}
+
+	if expression.IsLicense() {
+		switch expression.License {
+		case unknownLicense.License, "Error":
+			return nil
+		}
+
+		if identifier, ok := spdxLicenseIdentifier(expression.License); ok {
+			return CycloneDXLicenses{{License: &CycloneDXLicense{ID: identifier}}}
+		}
+
+		if converted := spdxLicenseExpression(license); strings.Contains(converted, " WITH ") {
+			return CycloneDXLicenses{{Expression: converted}}
+		}
+
+		return CycloneDXLicenses{{License: &CycloneDXLicense{Name: expression.License}}}
+	}
+
+	converted := spdxLicenseExpression(license)
+	if converted == spdxNoAssertion {
+		return nil
+	}
+
+	return CycloneDXLicenses{{Expression: converted}}
+}
+
+// cycloneDXEvidenceLicenses lists the licenses found in each license file. A
+// list may only hold a single expression, so if any of the files needs one
+// then they are all combined into it.
+func cycloneDXEvidenceLicenses(found []Expression) CycloneDXLicenses {
+	var licenses CycloneDXLicenses
+	seen := map[CycloneDXLicense]bool{}
+	for _, expression := range found {
+		for _, choice := range cycloneDXLicenses(expression.String()) {
+			if choice.License == nil {
+				return cycloneDXLicenses(CombineExpressions(ExpressionAnd, found...).String())
+			}
+
+			if !seen[*choice.License] {
+				seen[*choice.License] = true
+				licenses = append(licenses, choice)
+			}
+		}
+	}
+	return licenses
+}
+
+func (l CycloneDXLicenses) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, choice := range l {
+		var err error
+		if choice.License != nil {
+			err = e.EncodeElement(choice.License, xml.StartElement{Name: xml.Name{Local: "license"}})
+		} else {
+			err = e.EncodeElement(choice.Expression, xml.StartElement{Name: xml.Name{Local: "expression"}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func (d CycloneDXDependency) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: d.Ref}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, ref := range d.DependsOn {
+		child := xml.StartElement{
+			Name: xml.Name{Local: "dependency"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: ref}},
+		}
+		if err := e.EncodeToken(child); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(child.End()); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func (b CycloneDXBOM) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(b)
+}
+
+func (b CycloneDXBOM) WriteXML(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(b); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// randomUUID returns a random (version 4) UUID for the BOM's serial number.
+func randomUUID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		binary.BigEndian.PutUint64(bytes, uint64(time.Now().UnixNano()))
+	}
+	bytes[6] = bytes[6]&0x0f | 0x40
+	bytes[8] = bytes[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:16])
+}
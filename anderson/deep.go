@@ -0,0 +1,169 @@
+package anderson
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// ScanDeep looks for licenses that differ from the one a dependency was
+// classified under. It reads the license files between each of the package
+// directories in dirs and the directory that the dependency's license was
+// found in, and the SPDX identifiers in the headers of the Go files in the
+// package directories themselves. Anything that isn't covered by the
+// dependency's license is joined to it with AND, so the strictest license
+// found decides the status. Licenses asserted by an override are left alone.
+func (c LicenseClassifier) ScanDeep(classification Classification, importPath string, dirs []string) Classification {
+	if classification.Override != nil {
+		return classification
+	}
+
+	root := classification.License
+	if classification.Status == LicenseTypeNoLicense {
+		root = Expression{}
+	}
+
+	nested := c.nestedLicenseFiles(classification.Path, root, dirs)
+	if len(nested) == 0 {
+		return classification
+	}
+
+	trace := classification.Trace[:len(classification.Trace):len(classification.Trace)]
+	if len(trace) > 0 {
+		// The verdict is worked out again below.
+		trace = trace[:len(trace)-1]
+	}
+
+	var licenses []Expression
+	if root.License != "" || root.Operator != "" {
+		licenses = append(licenses, root)
+	}
+	for _, file := range nested {
+		rel, _ := filepath.Rel(classification.Path, file.Path)
+		trace = append(trace, fmt.Sprintf("deep scan found %s in %s: %s", file.License, rel, file.Match))
+		licenses = append(licenses, file.License)
+	}
+
+	expression := CombineExpressions(ExpressionAnd, licenses...)
+	if len(licenses) > 1 {
+		trace = append(trace, fmt.Sprintf("together the license is %s", expression))
+	}
+
+	deep := c.judge(classification.Path, importPath, expression, classification.Files, trace)
+	deep.Nested = nested
+
+	return deep
+}
+
+// nestedLicenseFiles finds the license files and SPDX headers in dirs that
+// state a license that root doesn't cover. Directories are searched up to but
+// not including licenseDir, whose license files are the ones that root came
+// from.
+func (c LicenseClassifier) nestedLicenseFiles(licenseDir string, root Expression, dirs []string) []LicenseFile {
+	var nested []LicenseFile
+	scanned := map[string]bool{}
+	searched := map[string]bool{}
+
+	for _, dir := range dirs {
+		if scanned[dir] {
+			continue
+		}
+		scanned[dir] = true
+
+		nested = append(nested, headerLicenseFiles(dir, root)...)
+
+		for ; dir != licenseDir && pathIsWithin(dir, licenseDir) && !searched[dir]; dir = filepath.Dir(dir) {
+			searched[dir] = true
+
+			_, files, err := c.identifyLicenses(dir)
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				if !coveredBy(file.License, root) {
+					nested = append(nested, file)
+				}
+			}
+		}
+	}
+
+	sort.Sort(byLicenseFilePath(nested))
+
+	return nested
+}
+
+// headerLicenseFiles finds the Go files in dir whose SPDX identifier states a
+// license that root doesn't cover.
+func headerLicenseFiles(dir string, root Expression) []LicenseFile {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []LicenseFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		header, err := sourceHeader(path)
+		if err != nil {
+			continue
+		}
+
+		match := spdxIdentifier.FindStringSubmatch(header)
+		if match == nil {
+			continue
+		}
+
+		expression, err := ParseExpression(match[1])
+		if err != nil || coveredBy(expression, root) {
+			continue
+		}
+
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		sha1sum := sha1.Sum(text)
+		sha256sum := sha256.Sum256(text)
+		files = append(files, LicenseFile{
+			Path:    path,
+			License: expression,
+			Match:   fmt.Sprintf("its header has SPDX-License-Identifier: %s", match[1]),
+			SHA1:    hex.EncodeToString(sha1sum[:]),
+			SHA256:  hex.EncodeToString(sha256sum[:]),
+			Header:  true,
+		})
+	}
+
+	return files
+}
+
+// coveredBy reports whether every license in an expression is already part
+// of root.
+func coveredBy(expression Expression, root Expression) bool {
+	if root.License == "" && root.Operator == "" {
+		return false
+	}
+
+	for _, license := range expression.Licenses() {
+		if !contains(root.Licenses(), license) {
+			return false
+		}
+	}
+	return true
+}
+
+type byLicenseFilePath []LicenseFile
+
+func (f byLicenseFilePath) Len() int           { return len(f) }
+func (f byLicenseFilePath) Less(i, j int) bool { return f[i].Path < f[j].Path }
+func (f byLicenseFilePath) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
@@ -0,0 +1,186 @@
+package anderson
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const exceptionDateFormat = "2006-01-02"
+
+// Exception allows dependencies whose license would otherwise fail the
+// build. Path is an import path, a glob such as "github.com/org/*" or a
+// prefix such as "github.com/org/..." that also matches every package below
+// it. When License is set the exception only applies while the dependency
+// has exactly that license. SHA256 pins the exception to the checksums of
+// the license files that were reviewed. Reason and ApprovedBy record why it
+// was allowed and who by, and it stops applying after the day it Expires.
+type Exception struct {
+	Path       string   `yaml:"path" json:"path"`
+	License    string   `yaml:"license,omitempty" json:"license,omitempty"`
+	SHA256     []string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	Reason     string   `yaml:"reason,omitempty" json:"reason,omitempty"`
+	ApprovedBy string   `yaml:"approved_by,omitempty" json:"approved_by,omitempty"`
+	Expires    string   `yaml:"expires,omitempty" json:"expires,omitempty"`
+}
+
+// UnmarshalYAML reads an exception that is either just an import path or a
+// mapping with the fields of an Exception.
+func (e *Exception) UnmarshalYAML(tag string, value interface{}) error {
+	if pointer, ok := value.(*interface{}); ok {
+		value = *pointer
+	}
+
+	switch value := value.(type) {
+	case string:
+		*e = Exception{Path: value}
+	case map[interface{}]interface{}:
+		*e = Exception{}
+		for key, field := range value {
+			text := yamlText(field)
+			switch key {
+			case "path":
+				e.Path = text
+			case "license":
+				e.License = text
+			case "sha256":
+				e.SHA256 = yamlList(field)
+			case "reason":
+				e.Reason = text
+			case "approved_by":
+				e.ApprovedBy = text
+			case "expires":
+				e.Expires = text
+			default:
+				return fmt.Errorf("unknown field %q in exception", key)
+			}
+		}
+	default:
+		return fmt.Errorf("exceptions must be import paths or mappings, not %v", value)
+	}
+
+	if e.Path == "" {
+		return fmt.Errorf("exception has no path")
+	}
+
+	if _, err := e.expiryDate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// yamlText turns a value from the YAML decoder back into text. Unquoted
+// dates have already been parsed into times.
+func yamlText(value interface{}) string {
+	switch value := value.(type) {
+	case time.Time:
+		return value.Format(exceptionDateFormat)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// yamlList reads a value that is either a single item or a list of them.
+func yamlList(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return []string{yamlText(value)}
+	}
+
+	list := []string{}
+	for _, item := range items {
+		list = append(list, yamlText(item))
+	}
+	return list
+}
+
+// Matches reports whether the exception covers a package with a license.
+func (e Exception) Matches(importPath string, license Expression) bool {
+	if e.License != "" && e.License != license.String() {
+		return false
+	}
+
+	if prefix := strings.TrimSuffix(e.Path, "/..."); prefix != e.Path {
+		return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+	}
+
+	matched, err := path.Match(e.Path, importPath)
+	return err == nil && matched
+}
+
+// MatchesFiles reports whether a package's license files are the ones that
+// the exception is pinned to. Exceptions that are not pinned match any
+// files.
+func (e Exception) MatchesFiles(files []LicenseFile) bool {
+	if len(e.SHA256) == 0 {
+		return true
+	}
+
+	if len(files) != len(e.SHA256) {
+		return false
+	}
+
+	for _, file := range files {
+		if !contains(e.SHA256, file.SHA256) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Expired reports whether the last day of the exception was before now.
+func (e Exception) Expired(now time.Time) bool {
+	expires, err := e.expiryDate()
+	if err != nil || expires.IsZero() {
+		return false
+	}
+
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+func (e Exception) expiryDate() (time.Time, error) {
+	if e.Expires == "" {
+		return time.Time{}, nil
+	}
+
+	expires, err := time.ParseInLocation(exceptionDateFormat, e.Expires, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("exception for %s expires on %q, which is not a YYYY-MM-DD date", e.Path, e.Expires)
+	}
+
+	return expires, nil
+}
+
+// Approval describes who approved the exception and until when.
+func (e Exception) Approval() string {
+	var parts []string
+	if e.ApprovedBy != "" {
+		parts = append(parts, "approved by "+e.ApprovedBy)
+	}
+	if e.Expires != "" {
+		parts = append(parts, "until "+e.Expires)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ExpiredExceptions splits off the exceptions that have expired by now. They
+// are returned separately and removed from the config so that they no
+// longer apply.
+func (c Config) ExpiredExceptions(now time.Time) (Config, []Exception) {
+	var active, expired []Exception
+	for _, exception := range c.Exceptions {
+		if exception.Expired(now) {
+			expired = append(expired, exception)
+		} else {
+			active = append(active, exception)
+		}
+	}
+
+	c.Exceptions = active
+	return c, expired
+}
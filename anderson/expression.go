@@ -0,0 +1,216 @@
+package anderson
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	ExpressionAnd = "AND"
+	ExpressionOr  = "OR"
+)
+
+// Expression is a parsed SPDX license expression. It is either a single
+// License or an Operator applied to two or more Operands.
+type Expression struct {
+	License  string
+	Operator string
+	Operands []Expression
+}
+
+func NewLicenseExpression(license string) Expression {
+	return Expression{License: license}
+}
+
+// CombineExpressions joins expressions with an operator, flattening any
+// operands that already use it and dropping duplicates.
+func CombineExpressions(operator string, expressions ...Expression) Expression {
+	var operands []Expression
+	seen := map[string]bool{}
+
+	for _, expression := range expressions {
+		children := []Expression{expression}
+		if expression.Operator == operator {
+			children = expression.Operands
+		}
+
+		for _, child := range children {
+			if seen[child.String()] {
+				continue
+			}
+			seen[child.String()] = true
+			operands = append(operands, child)
+		}
+	}
+
+	if len(operands) == 1 {
+		return operands[0]
+	}
+
+	return Expression{Operator: operator, Operands: operands}
+}
+
+func (e Expression) IsLicense() bool {
+	return e.Operator == ""
+}
+
+// Licenses returns every license mentioned in the expression in the order
+// they appear.
+func (e Expression) Licenses() []string {
+	if e.IsLicense() {
+		return []string{e.License}
+	}
+
+	var licenses []string
+	for _, operand := range e.Operands {
+		licenses = append(licenses, operand.Licenses()...)
+	}
+	return licenses
+}
+
+func (e Expression) String() string {
+	if e.IsLicense() {
+		return e.License
+	}
+
+	parts := make([]string, len(e.Operands))
+	for i, operand := range e.Operands {
+		parts[i] = operand.String()
+		if !operand.IsLicense() {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+
+	return strings.Join(parts, " "+e.Operator+" ")
+}
+
+func (e Expression) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// ParseExpression parses an SPDX license expression such as
+// "MIT OR (Apache-2.0 AND GPL-2.0+ WITH Classpath-exception-2.0)". AND binds
+// more tightly than OR. A license WITH an exception is kept together as a
+// single license.
+func ParseExpression(expression string) (Expression, error) {
+	parser := &expressionParser{tokens: tokenizeExpression(expression)}
+	if len(parser.tokens) == 0 {
+		return Expression{}, fmt.Errorf("empty license expression")
+	}
+
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return Expression{}, err
+	}
+
+	if parser.position < len(parser.tokens) {
+		return Expression{}, fmt.Errorf("unexpected %q in license expression %q", parser.tokens[parser.position], expression)
+	}
+
+	return parsed, nil
+}
+
+type expressionParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *expressionParser) peek() string {
+	if p.position >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.position]
+}
+
+func (p *expressionParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+func (p *expressionParser) parseOr() (Expression, error) {
+	return p.parseOperator(ExpressionOr, p.parseAnd)
+}
+
+func (p *expressionParser) parseAnd() (Expression, error) {
+	return p.parseOperator(ExpressionAnd, p.parseTerm)
+}
+
+func (p *expressionParser) parseOperator(operator string, operand func() (Expression, error)) (Expression, error) {
+	first, err := operand()
+	if err != nil {
+		return Expression{}, err
+	}
+
+	operands := []Expression{first}
+	for strings.ToUpper(p.peek()) == operator {
+		p.next()
+		next, err := operand()
+		if err != nil {
+			return Expression{}, err
+		}
+		operands = append(operands, next)
+	}
+
+	return CombineExpressions(operator, operands...), nil
+}
+
+func (p *expressionParser) parseTerm() (Expression, error) {
+	token := p.next()
+
+	switch strings.ToUpper(token) {
+	case "":
+		return Expression{}, fmt.Errorf("license expression ended unexpectedly")
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return Expression{}, err
+		}
+		if p.next() != ")" {
+			return Expression{}, fmt.Errorf("missing closing parenthesis in license expression")
+		}
+		return inner, nil
+	case ")", ExpressionAnd, ExpressionOr, "WITH":
+		return Expression{}, fmt.Errorf("unexpected %q in license expression", token)
+	}
+
+	license := token
+	if strings.ToUpper(p.peek()) == "WITH" {
+		p.next()
+		exception := p.next()
+		if exception == "" || exception == "(" || exception == ")" {
+			return Expression{}, fmt.Errorf("missing exception after WITH in license expression")
+		}
+		license = license + " WITH " + exception
+	}
+
+	return NewLicenseExpression(license), nil
+}
+
+func tokenizeExpression(expression string) []string {
+	var tokens []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range expression {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
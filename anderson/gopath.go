@@ -24,18 +24,8 @@ func LookGopath(packagePath string) (string, error) {
 		return "", err
 	}
 
-	for _, dir := range paths {
-		if dir == "" {
-			// Unix shell semantics: path element "" means "."
-			dir = "."
-		}
-		path := filepath.Join(dir, "src", packagePath)
-		if err := findPackage(path); err == nil {
-			return path, nil
-		}
-	}
-
-	return "", errors.New("could not find package in GOPATH")
+	_, path, err := lookGopath(paths, packagePath)
+	return path, err
 }
 
 func ContainingGopath(packagePath string) (string, error) {
@@ -44,6 +34,13 @@ func ContainingGopath(packagePath string) (string, error) {
 		return "", err
 	}
 
+	gopath, _, err := lookGopath(paths, packagePath)
+	return gopath, err
+}
+
+// lookGopath finds the first of paths that has the package and returns it
+// along with the package's directory.
+func lookGopath(paths []string, packagePath string) (string, string, error) {
 	for _, dir := range paths {
 		if dir == "" {
 			// Unix shell semantics: path element "" means "."
@@ -51,11 +48,24 @@ func ContainingGopath(packagePath string) (string, error) {
 		}
 		path := filepath.Join(dir, "src", packagePath)
 		if err := findPackage(path); err == nil {
-			return dir, nil
+			return dir, path, nil
+		}
+	}
+
+	return "", "", errors.New("could not find package in GOPATH")
+}
+
+// containingGopathSrc finds the src directory of the GOPATH entry that dir
+// is in. Both are compared with their symlinks resolved.
+func containingGopathSrc(paths []string, dir string) (string, bool) {
+	for _, gopath := range paths {
+		src, err := filepath.EvalSymlinks(filepath.Join(gopath, "src"))
+		if err == nil && pathIsWithin(dir, src) {
+			return src, true
 		}
 	}
 
-	return "", errors.New("could not find package in GOPATH")
+	return "", false
 }
 
 func Gopaths() ([]string, error) {
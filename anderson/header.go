@@ -0,0 +1,140 @@
+package anderson
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headerPhrases are the sentences that Go files commonly state their license
+// with in their header. The more specific phrases come first. Licenses are
+// named the way they are when they are found in a license file.
+var headerPhrases = []struct {
+	phrase  *regexp.Regexp
+	license string
+}{
+	{regexp.MustCompile(`apache license,? version 2\.0`), "Apache-2.0"},
+	{regexp.MustCompile(`mozilla public license,? v(ersion|\.) ?2\.0`), "MPL-2.0"},
+	{regexp.MustCompile(`gnu affero general public license`), "AGPL-3.0"},
+	{regexp.MustCompile(`gnu lesser general public license.*version 2\.1`), "LGPL-2.1"},
+	{regexp.MustCompile(`gnu lesser general public license.*version 3`), "LGPL-3.0"},
+	{regexp.MustCompile(`gnu general public license.*version 2`), "GPL-2.0"},
+	{regexp.MustCompile(`gnu general public license.*version 3`), "GPL-3.0"},
+	{regexp.MustCompile(`governed by a bsd-style license`), "NewBSD"},
+	{regexp.MustCompile(`governed by an mit-style license|under the mit license`), "MIT"},
+	{regexp.MustCompile(`under the isc license`), "ISC"},
+}
+
+var headerWhitespace = regexp.MustCompile(`\s+`)
+
+// identifyLicenseHeaders works out the license of a package from the headers
+// of its Go files, for packages that don't have a license file. Every
+// license that a header states applies, so they are joined with AND. The
+// first file to state each license is kept as the evidence for it.
+func identifyLicenseHeaders(path string) (Expression, []LicenseFile, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return Expression{}, nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".go" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var files []LicenseFile
+	var licenses []Expression
+	for _, name := range names {
+		filePath := filepath.Join(path, name)
+		header, err := sourceHeader(filePath)
+		if err != nil {
+			continue
+		}
+
+		expression, match, ok := identifyLicenseHeader(header)
+		if !ok || containsExpression(licenses, expression) {
+			continue
+		}
+
+		text, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return Expression{}, nil, err
+		}
+
+		sha1sum := sha1.Sum(text)
+		sha256sum := sha256.Sum256(text)
+		files = append(files, LicenseFile{
+			Path:    filePath,
+			License: expression,
+			Match:   match,
+			SHA1:    hex.EncodeToString(sha1sum[:]),
+			SHA256:  hex.EncodeToString(sha256sum[:]),
+			Header:  true,
+		})
+		licenses = append(licenses, expression)
+	}
+
+	if len(files) == 0 {
+		return Expression{}, nil, nil
+	}
+
+	return CombineExpressions(ExpressionAnd, licenses...), files, nil
+}
+
+// identifyLicenseHeader returns the license that a file's header states
+// along with a description of how it says so.
+func identifyLicenseHeader(header string) (Expression, string, bool) {
+	if match := spdxIdentifier.FindStringSubmatch(header); match != nil {
+		if expression, err := ParseExpression(match[1]); err == nil {
+			return expression, fmt.Sprintf("its header has SPDX-License-Identifier: %s", match[1]), true
+		}
+	}
+
+	text := headerWhitespace.ReplaceAllString(strings.ToLower(header), " ")
+	for _, phrase := range headerPhrases {
+		if found := phrase.phrase.FindString(text); found != "" {
+			return NewLicenseExpression(phrase.license), fmt.Sprintf("its header says %q", found), true
+		}
+	}
+
+	return Expression{}, "", false
+}
+
+// sourceHeader is the comments at the top of a Go file, before its package
+// clause and its package documentation, without their comment markers.
+func sourceHeader(path string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	var comments []string
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package || group == file.Doc {
+			break
+		}
+		comments = append(comments, group.Text())
+	}
+
+	return strings.Join(comments, "\n"), nil
+}
+
+func containsExpression(expressions []Expression, expression Expression) bool {
+	for _, existing := range expressions {
+		if existing.String() == expression.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package anderson
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JUnitTestSuites is a JUnit XML report with a test case for every dependency
+// so that CI servers can show license problems next to failing tests.
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Properties []JUnitProperty `xml:"properties>property,omitempty"`
+	Failure    *JUnitFailure   `xml:"failure,omitempty"`
+}
+
+type JUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",cdata"`
+}
+
+// NewJUnitReport turns a report into a single test suite. Dependencies whose
+// status fails the build are failed test cases, as is every exception that
+// has expired.
+func NewJUnitReport(rootName string, report Report, created time.Time) JUnitTestSuites {
+	suite := JUnitTestSuite{
+		Name:      "anderson",
+		Timestamp: created.UTC().Format("2006-01-02T15:04:05"),
+		TestCases: []JUnitTestCase{},
+	}
+
+	for _, dependency := range report.Dependencies {
+		testCase := JUnitTestCase{
+			Name:      dependency.LicensePath,
+			ClassName: rootName,
+			Properties: []JUnitProperty{
+				{Name: "scope", Value: string(dependency.Scope)},
+			},
+		}
+
+		if dependency.FailsBuild {
+			testCase.Failure = &JUnitFailure{
+				Message: fmt.Sprintf("%s: %s", dependency.License, dependency.Status.Message()),
+				Type:    dependency.Status.String(),
+				Details: junitFailureDetails(dependency),
+			}
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+	}
+
+	for _, exception := range report.ExpiredExceptions {
+		suite.TestCases = append(suite.TestCases, JUnitTestCase{
+			Name:      exception.Path,
+			ClassName: rootName + ".exceptions",
+			Failure: &JUnitFailure{
+				Message: fmt.Sprintf("exception expired on %s", exception.Expires),
+				Type:    "expired-exception",
+				Details: strings.Join([]string{
+					fmt.Sprintf("Approved by: %s", exception.ApprovedBy),
+					fmt.Sprintf("Reason: %s", exception.Reason),
+				}, "\n"),
+			},
+		})
+		suite.Failures++
+		suite.Tests++
+	}
+
+	for _, packageError := range report.PackageErrors {
+		suite.TestCases = append(suite.TestCases, JUnitTestCase{
+			Name:      packageError.ImportPath,
+			ClassName: rootName + ".packages",
+			Failure: &JUnitFailure{
+				Message: "package could not be loaded",
+				Type:    "load-error",
+				Details: packageError.Err,
+			},
+		})
+		suite.Failures++
+		suite.Tests++
+	}
+
+	return JUnitTestSuites{
+		Name:     rootName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []JUnitTestSuite{suite},
+	}
+}
+
+func junitFailureDetails(dependency Dependency) string {
+	lines := []string{
+		fmt.Sprintf("Import path: %s", dependency.ImportPath),
+		fmt.Sprintf("Scope: %s", dependency.Scope),
+		fmt.Sprintf("License: %s", dependency.License),
+	}
+
+	if dependency.Override != nil {
+		lines = append(lines, fmt.Sprintf("License asserted by override: %s", dependency.Override.Reference))
+	}
+
+	if dependency.ElectedLicense != dependency.License {
+		lines = append(lines, fmt.Sprintf("Elected license: %s", dependency.ElectedLicense))
+	}
+
+	lines = append(lines, fmt.Sprintf("Status: %s", dependency.Status.Message()))
+
+	if dependency.Rule != nil {
+		lines = append(lines, fmt.Sprintf("Rule: %s entry %s", dependency.Rule.List, dependency.Rule.Entry))
+	}
+
+	for _, file := range dependency.LicenseFiles {
+		lines = append(lines, fmt.Sprintf("License file: %s", file.Path))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (j JUnitTestSuites) WriteXML(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(j); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,185 @@
+package anderson
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ryanuber/go-license"
+)
+
+type licenseFileKind int
+
+const (
+	requiredLicenseFile licenseFileKind = iota
+	alternativeLicenseFile
+	noticeLicenseFile
+	reuseLicenseFile
+)
+
+// reuseLicensesDir is the directory that projects following the REUSE
+// specification keep their license texts in, one per license and named after
+// its SPDX identifier.
+const reuseLicensesDir = "LICENSES"
+
+// licenseFilePatterns are the names that license files go by, matched
+// without regard to case. The first pattern that a name matches decides how
+// the file is read, so the list goes from the most to the least certain.
+// Patterns from the extra_license_files setting are tried after the license
+// names and before the NOTICE files.
+//
+// A file whose name says which license it holds, like LICENSE-MIT,
+// MIT-LICENSE or LICENSE.apache2, is one of a choice of licenses. A file
+// that is only called LICENSE or COPYING, whatever its document extension,
+// always applies.
+var licenseFilePatterns = []struct {
+	pattern string
+	kind    licenseFileKind
+}{
+	{"license", requiredLicenseFile},
+	{"licence", requiredLicenseFile},
+	{"license.*", requiredLicenseFile},
+	{"licence.*", requiredLicenseFile},
+	{"copying", requiredLicenseFile},
+	{"copying.*", requiredLicenseFile},
+	{"unlicense", requiredLicenseFile},
+	{"unlicense.*", requiredLicenseFile},
+	{"license-*", alternativeLicenseFile},
+	{"licence-*", alternativeLicenseFile},
+	{"license_*", alternativeLicenseFile},
+	{"licence_*", alternativeLicenseFile},
+	{"*-license", alternativeLicenseFile},
+	{"*-licence", alternativeLicenseFile},
+	{"*-license.*", alternativeLicenseFile},
+	{"*-licence.*", alternativeLicenseFile},
+	{"notice", noticeLicenseFile},
+	{"notice.*", noticeLicenseFile},
+}
+
+// foundLicenseFile is a file that may hold a license, named relative to the
+// directory it was found in with slashes.
+type foundLicenseFile struct {
+	name string
+	kind licenseFileKind
+}
+
+type byFoundLicenseFileName []foundLicenseFile
+
+func (f byFoundLicenseFileName) Len() int           { return len(f) }
+func (f byFoundLicenseFileName) Less(i, j int) bool { return f[i].name < f[j].name }
+func (f byFoundLicenseFileName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// licenseFiles lists the files in a directory that look like they contain a
+// license: those whose names match licenseFilePatterns or one of the extra
+// patterns, and the files in a REUSE LICENSES directory. Extra patterns are
+// globs relative to the directory and can reach into its subdirectories.
+func licenseFiles(dir string, extra []string) ([]foundLicenseFile, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := map[string]licenseFileKind{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".go" {
+			continue
+		}
+
+		if kind, ok := licenseFileKindOf(entry.Name()); ok && kind != noticeLicenseFile {
+			kinds[entry.Name()] = kind
+		}
+	}
+
+	for _, pattern := range extra {
+		// Bad patterns are reported when the config is validated.
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			name, err := filepath.Rel(dir, match)
+			if err != nil || strings.HasPrefix(name, "..") {
+				continue
+			}
+
+			name = filepath.ToSlash(name)
+			if _, ok := kinds[name]; !ok {
+				kinds[name] = requiredLicenseFile
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".go" {
+			continue
+		}
+
+		if kind, ok := licenseFileKindOf(entry.Name()); ok && kind == noticeLicenseFile {
+			if _, claimed := kinds[entry.Name()]; !claimed {
+				kinds[entry.Name()] = kind
+			}
+		}
+	}
+
+	reuse, err := ioutil.ReadDir(filepath.Join(dir, reuseLicensesDir))
+	if err == nil {
+		for _, entry := range reuse {
+			name := reuseLicensesDir + "/" + entry.Name()
+			if _, claimed := kinds[name]; !claimed && !entry.IsDir() {
+				kinds[name] = reuseLicenseFile
+			}
+		}
+	}
+
+	var files []foundLicenseFile
+	for name, kind := range kinds {
+		files = append(files, foundLicenseFile{name: name, kind: kind})
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New(license.ErrNoLicenseFile)
+	}
+
+	sort.Sort(byFoundLicenseFileName(files))
+
+	return files, nil
+}
+
+// documentExtensions are the extensions that say what format a license file
+// is written in rather than which license it holds.
+var documentExtensions = []string{".txt", ".md", ".markdown", ".rst", ".adoc", ".org", ".html", ".htm", ".rtf"}
+
+// licenseFileKindOf finds the first of licenseFilePatterns that a file name
+// matches. A LICENSE or COPYING file with an extension that isn't a document
+// format is named after its license and so is one of a choice.
+func licenseFileKindOf(name string) (licenseFileKind, bool) {
+	name = strings.ToLower(name)
+	for _, pattern := range licenseFilePatterns {
+		if matched, _ := path.Match(pattern.pattern, name); matched {
+			if pattern.kind == requiredLicenseFile && path.Ext(name) != "" && !contains(documentExtensions, path.Ext(name)) {
+				return alternativeLicenseFile, true
+			}
+			return pattern.kind, true
+		}
+	}
+	return requiredLicenseFile, false
+}
+
+// reuseLicense reads the license that a file in a REUSE LICENSES directory
+// is named after, such as MIT for LICENSES/MIT.txt.
+func reuseLicense(name string) (Expression, bool) {
+	id := strings.TrimSuffix(path.Base(name), ".txt")
+
+	expression, err := ParseExpression(id)
+	if err != nil || !expression.IsLicense() {
+		return Expression{}, false
+	}
+
+	return expression, true
+}
@@ -3,7 +3,6 @@ package anderson
 import (
 	"bufio"
 	"encoding/json"
-	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -26,8 +25,11 @@ type Package struct {
 	Dir        string
 	Root       string
 	ImportPath string
-	Deps       []string
+	Imports    []string
 	Standard   bool
+	DepOnly    bool
+	ForTest    string
+	Module     *Module
 
 	TestGoFiles  []string
 	TestImports  []string
@@ -39,23 +41,145 @@ type Package struct {
 	}
 }
 
-type PackageLister struct{}
+// Workspace is every package that the project's packages, tests and tools
+// are made of, loaded by a single go list. Packages that could not be loaded
+// are kept with their error so that one broken import doesn't stop the rest
+// of the project from being scanned.
+type Workspace struct {
+	Packages []*Package
+}
+
+// PackageError is a package that could not be loaded and why.
+type PackageError struct {
+	ImportPath string `json:"import_path"`
+	Err        string `json:"error"`
+}
 
-func (l PackageLister) ListDependencies() ([]string, error) {
-	packages, err := l.loadPackages("./...")
+// LoadWorkspace loads the packages in the current directory, their tests, the
+// main module's tools and everything they depend on.
+func LoadWorkspace() (Workspace, error) {
+	packages, err := loadProjectPackages()
 	if err != nil {
-		return []string{}, err
+		return Workspace{}, err
+	}
+
+	return Workspace{Packages: packages}, nil
+}
+
+// ListDependencies lists the packages that the project depends on. Packages
+// below one that is listed already share its license and are left out, as
+// are packages that could not be found at all.
+func (w Workspace) ListDependencies() ([]string, error) {
+	found := map[string]bool{}
+	for _, pkg := range w.Packages {
+		if !pkg.isDependency() || pkg.Dir == "" {
+			continue
+		}
+		found[withoutTestVariant(pkg.ImportPath)] = true
+	}
+
+	paths := []string{}
+	for importPath := range found {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	var seen []string
+	dependencies := []string{}
+	for _, importPath := range paths {
+		if containsPathPrefix(seen, importPath) {
+			continue
+		}
+
+		seen = append(seen, importPath)
+		dependencies = append(dependencies, importPath)
+	}
+
+	return dependencies, nil
+}
+
+// PackageDirs lists the directories of the packages in the workspace that
+// are importPath or below it, which are the parts of a dependency that the
+// project actually uses.
+func (w Workspace) PackageDirs(importPath string) []string {
+	var dirs []string
+	for _, pkg := range w.Packages {
+		if pkg.Standard || pkg.Dir == "" || contains(dirs, pkg.Dir) {
+			continue
+		}
+
+		if containsPathPrefix([]string{importPath}, withoutTestVariant(pkg.ImportPath)) {
+			dirs = append(dirs, pkg.Dir)
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// Errors lists the packages that could not be loaded, once each.
+func (w Workspace) Errors() []PackageError {
+	var errors []PackageError
+	for _, pkg := range w.Packages {
+		if pkg.Error.Err == "" {
+			continue
+		}
+
+		packageError := PackageError{
+			ImportPath: withoutTestVariant(pkg.ImportPath),
+			Err:        strings.TrimSpace(pkg.Error.Err),
+		}
+		if !containsPackageError(errors, packageError) {
+			errors = append(errors, packageError)
+		}
 	}
 
-	return l.listDeps(packages)
+	sort.Sort(byErrorImportPath(errors))
+
+	return errors
 }
 
-func (l PackageLister) loadPackages(name ...string) (packages []*Package, err error) {
+// isDependency reports whether a package belongs to a dependency rather than
+// to the standard library or the project being scanned.
+func (p *Package) isDependency() bool {
+	if p.Standard || strings.HasSuffix(p.ImportPath, ".test") {
+		return false
+	}
+
+	if p.Module != nil {
+		return !p.Module.Main
+	}
+
+	return p.DepOnly
+}
+
+func containsPackageError(errors []PackageError, packageError PackageError) bool {
+	for _, existing := range errors {
+		if existing == packageError {
+			return true
+		}
+	}
+	return false
+}
+
+type byErrorImportPath []PackageError
+
+func (e byErrorImportPath) Len() int           { return len(e) }
+func (e byErrorImportPath) Less(i, j int) bool { return e[i].ImportPath < e[j].ImportPath }
+func (e byErrorImportPath) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// packageFields are the fields of Package that go list is asked for. Leaving
+// out the rest, Deps above all, keeps the output from growing with the square
+// of the size of the workspace.
+const packageFields = "Dir,Root,ImportPath,Imports,Standard,DepOnly,ForTest,Module,TestGoFiles,TestImports,XTestGoFiles,XTestImports,Error"
+
+func loadPackages(name ...string) (packages []*Package, err error) {
 	if len(name) == 0 {
 		return nil, nil
 	}
 
-	args := []string{"list", "-e", "-json"}
+	args := []string{"list", "-e", "-json=" + packageFields}
 	cmd := exec.Command("go", append(args, name...)...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -89,128 +213,233 @@ func (l PackageLister) loadPackages(name ...string) (packages []*Package, err er
 	return packages, nil
 }
 
-func (l PackageLister) listDeps(packages []*Package) ([]string, error) {
-	var err error
-	var path, seen []string
+// CurrentPackageName is the import path of the package in the current
+// directory or, when using modules, the path of the main module.
+func CurrentPackageName() (string, error) {
+	cmd := exec.Command("go", "list")
+	if env, err := LoadGoEnv(); err == nil && env.ModulesEnabled() {
+		cmd = exec.Command("go", "list", "-m")
+	}
 
-	dependencies := []string{}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
 
-	for _, pkg := range packages {
-		if pkg.Standard {
-			continue
-		}
+	return strings.TrimSpace(string(output)), nil
+}
 
-		if pkg.Error.Err != "" {
-			err = fmt.Errorf("error loading packages: %s", pkg.Error.Err)
-			continue
+// containsPathPrefix reports whether s is one of pats or a package below one
+// of them. Packages in a vendor directory below a pattern are a different
+// project and do not count.
+func containsPathPrefix(pats []string, s string) bool {
+	for _, pat := range pats {
+		if pat == s {
+			return true
+		}
+		if strings.HasPrefix(s, pat+"/") && !strings.Contains(s[len(pat):]+"/", "/vendor/") {
+			return true
 		}
-
-		path = append(path, pkg.Deps...)
 	}
+	return false
+}
 
-	if err != nil {
-		return []string{}, err
-	}
+const (
+	testRootSuffix = " (test)"
+	toolRootSuffix = " (tool)"
+)
 
-	var testImports []string
-	for _, pkg := range packages {
-		testImports = append(testImports, pkg.TestImports...)
-		testImports = append(testImports, pkg.XTestImports...)
-	}
+// ImportGraph records what every package directly imports. Roots are the
+// packages of the project being scanned. The tests of a package are a
+// separate root named like "example.com/pkg (test)" and the tools that the
+// main module's go.mod lists are imported by a root named like
+// "example.com/module (tool)".
+type ImportGraph struct {
+	Roots   []string
+	Imports map[string][]string
+}
 
-	testPackages, err := l.loadPackages(testImports...)
-	if err != nil {
-		return []string{}, err
-	}
+// ImportGraph works out what the workspace's packages import. Dependencies
+// that are recompiled for a test are merged with the package they were
+// compiled from and vendored packages are known by their import path without
+// the vendor directory.
+func (w Workspace) ImportGraph() ImportGraph {
+	graph := ImportGraph{Imports: map[string][]string{}}
+
+	var mainModule string
+	var tools []string
 
-	for _, pkg := range testPackages {
+	for _, pkg := range w.Packages {
 		if pkg.Standard {
 			continue
 		}
 
-		if pkg.Error.Err != "" {
-			err = fmt.Errorf("error loading packages: %s", pkg.Error.Err)
+		// The project's packages compiled for their tests and the main
+		// packages of the test binaries are covered by the test roots below.
+		if !pkg.DepOnly && (pkg.ForTest != "" || strings.HasSuffix(pkg.ImportPath, ".test")) {
 			continue
 		}
 
-		path = append(path, pkg.ImportPath)
-		path = append(path, pkg.Deps...)
+		importPath := graphPath(pkg.ImportPath)
+		graph.addImports(importPath, pkg.Imports)
+
+		if pkg.DepOnly {
+			continue
+		}
+
+		if pkg.Module != nil && !pkg.Module.Main {
+			tools = append(tools, importPath)
+			continue
+		}
+
+		if pkg.Module != nil {
+			mainModule = pkg.Module.Path
+		}
+
+		if !contains(graph.Roots, importPath) {
+			graph.Roots = append(graph.Roots, importPath)
+		}
+
+		testImports := append(append([]string{}, pkg.TestImports...), pkg.XTestImports...)
+		if len(testImports) > 0 {
+			tests := importPath + testRootSuffix
+			graph.Roots = append(graph.Roots, tests)
+			graph.addImports(tests, testImports)
+		}
 	}
 
-	if err != nil {
-		return []string{}, err
+	if len(tools) > 0 {
+		toolRoot := mainModule + toolRootSuffix
+		graph.Roots = append(graph.Roots, toolRoot)
+		graph.addImports(toolRoot, tools)
 	}
 
-	sort.Strings(path)
-	path = uniq(path)
+	sort.Strings(graph.Roots)
 
-	allPackages, err := l.loadPackages(path...)
-	if err != nil {
-		return []string{}, err
-	}
+	return graph
+}
 
-	currentName, err := currentPackageName()
-	if err != nil {
-		return []string{}, err
+// Scopes works out the scope of every package that the project's packages,
+// tests and tools import.
+func (g ImportGraph) Scopes() Scopes {
+	scopes := Scopes{}
+	for _, root := range g.Roots {
+		scope := rootScope(root)
+		queue := append([]string{}, g.Imports[root]...)
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			if contains(g.Roots, current) {
+				continue
+			}
+
+			if existing, ok := scopes[current]; ok && !scope.WiderThan(existing) {
+				continue
+			}
+
+			scopes[current] = scope
+			queue = append(queue, g.Imports[current]...)
+		}
 	}
+	return scopes
+}
 
-	for _, pkg := range allPackages {
-		if pkg.Error.Err != "" {
-			err = fmt.Errorf("error loading dependencies: %s", pkg.Error.Err)
-			continue
+func (g ImportGraph) addImports(importPath string, imports []string) {
+	for _, imported := range imports {
+		imported = graphPath(imported)
+		if imported != importPath && !contains(g.Imports[importPath], imported) {
+			g.Imports[importPath] = append(g.Imports[importPath], imported)
 		}
+	}
+}
 
-		if pkg.Standard {
-			continue
+// Why returns the shortest chains of imports that lead from the project's
+// packages to importPath or any package below it. Each chain starts at a
+// different project package and does not pass through any of the others.
+func (g ImportGraph) Why(importPath string) [][]string {
+	var chains [][]string
+	for _, root := range g.Roots {
+		if chain := g.shortestChain(root, importPath); chain != nil {
+			chains = append(chains, chain)
 		}
+	}
 
-		if containsPathPrefix(seen, pkg.ImportPath) {
-			continue
+	sort.Sort(byChainLength(chains))
+
+	return chains
+}
+
+func (g ImportGraph) shortestChain(root string, importPath string) []string {
+	previous := map[string]string{root: ""}
+	queue := []string{root}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current != root && (current == importPath || strings.HasPrefix(current, importPath+"/")) {
+			chain := []string{}
+			for node := current; node != ""; node = previous[node] {
+				chain = append([]string{node}, chain...)
+			}
+			return chain
 		}
 
-		if strings.HasPrefix(pkg.ImportPath, currentName) {
+		if current != root && contains(g.Roots, current) {
 			continue
 		}
 
-		seen = append(seen, pkg.ImportPath)
-		dependencies = append(dependencies, pkg.ImportPath)
-	}
-
-	if err != nil {
-		return []string{}, err
+		imports := append([]string{}, g.Imports[current]...)
+		sort.Strings(imports)
+		for _, imported := range imports {
+			if _, seen := previous[imported]; !seen {
+				previous[imported] = current
+				queue = append(queue, imported)
+			}
+		}
 	}
 
-	return dependencies, nil
+	return nil
 }
 
-func currentPackageName() (string, error) {
-	cmd := exec.Command("go", "list")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
+type byChainLength [][]string
+
+func (c byChainLength) Len() int      { return len(c) }
+func (c byChainLength) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byChainLength) Less(i, j int) bool {
+	if len(c[i]) != len(c[j]) {
+		return len(c[i]) < len(c[j])
 	}
+	return strings.Join(c[i], " ") < strings.Join(c[j], " ")
+}
 
-	return strings.TrimSpace(string(output)), nil
+// graphPath is the path that a package is known by in the import graph.
+// Packages compiled for tests are merged with the package and vendored
+// packages go by the import path that they were vendored from.
+func graphPath(importPath string) string {
+	return VendorlessPath(withoutTestVariant(importPath))
 }
 
-func uniq(a []string) []string {
-	i := 0
-	s := ""
-	for _, t := range a {
-		if t != s {
-			a[i] = t
-			i++
-			s = t
-		}
+// withoutTestVariant turns the import path of a package compiled for a test,
+// such as "example.com/pkg [example.com/pkg.test]", back into the import path
+// of the package.
+func withoutTestVariant(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
 	}
-	return a[:i]
+	return importPath
 }
 
-func containsPathPrefix(pats []string, s string) bool {
-	for _, pat := range pats {
-		if pat == s || strings.HasPrefix(s, pat+"/") {
-			return true
-		}
+// loadProjectPackages runs go list on the packages in the current directory
+// with their tests and dependencies. The tools that the main module's go.mod
+// lists are loaded too, which they cannot be before Go 1.24 or outside a
+// module.
+func loadProjectPackages() ([]*Package, error) {
+	patterns := []string{"-deps", "-test", "./..."}
+	if tools, err := loadModuleTools(); err == nil && len(tools) > 0 {
+		patterns = append(patterns, "tool")
 	}
-	return false
+
+	return loadPackages(patterns...)
 }
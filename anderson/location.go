@@ -0,0 +1,179 @@
+package anderson
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Location is where the source of an imported package lives on disk. Root is
+// the directory that bounds the search for a license: the GOPATH's src
+// directory, the vendor directory that the package was vendored into or the
+// root of the module that provides the package. Upstream is where the copy
+// that a vendored package was taken from is, if it can be found.
+type Location struct {
+	Dir            string
+	Root           string
+	RootImportPath string
+	Version        string
+	Upstream       *Location
+}
+
+func (l Location) ImportPath(dir string) (string, error) {
+	rel, err := filepath.Rel(l.Root, dir)
+	if err != nil {
+		return "", err
+	}
+
+	if !pathIsWithin(dir, l.Root) {
+		return "", errors.New("path is outside of " + l.Root)
+	}
+
+	return path.Join(l.RootImportPath, filepath.ToSlash(rel)), nil
+}
+
+// GopathResolver finds packages in the GOPATH. Vendored copies are preferred
+// the way the go tool prefers them: a vendored import path such as
+// "github.com/a/vendor/github.com/b/c" is found at exactly that path, and a
+// plain import path is looked for in the vendor directories of the current
+// directory and each of its parents before the rest of the GOPATH.
+type GopathResolver struct {
+	gopaths []string
+	wd      string
+}
+
+// NewGopathResolver reads the GOPATH and the current directory once so that
+// resolving each dependency doesn't have to.
+func NewGopathResolver() (GopathResolver, error) {
+	gopaths, err := Gopaths()
+	if err != nil {
+		return GopathResolver{}, err
+	}
+
+	wd, err := os.Getwd()
+	if err == nil {
+		wd, err = filepath.EvalSymlinks(wd)
+	}
+	if err != nil {
+		return GopathResolver{}, err
+	}
+
+	return GopathResolver{gopaths: gopaths, wd: wd}, nil
+}
+
+func (r GopathResolver) Resolve(importPath string) (Location, error) {
+	if VendorlessPath(importPath) != importPath {
+		return r.resolveVendored(importPath)
+	}
+
+	if location, ok := r.lookVendor(importPath); ok {
+		return location, nil
+	}
+
+	return r.resolve(importPath)
+}
+
+func (r GopathResolver) resolve(importPath string) (Location, error) {
+	gopath, dir, err := lookGopath(r.gopaths, importPath)
+	if err != nil {
+		return Location{}, err
+	}
+
+	return Location{
+		Dir:  dir,
+		Root: filepath.Join(gopath, "src"),
+	}, nil
+}
+
+// UnvendoredLicenseFiles lists the license files that the upstream copy of a
+// vendored package has but that were not found with the vendored copy.
+func (l Location) UnvendoredLicenseFiles(found []LicenseFile) []string {
+	if l.Upstream == nil {
+		return nil
+	}
+
+	upstream, err := LicenseClassifier{}.Classify(l.Upstream.Dir, l.Upstream.Root, "")
+	if err != nil {
+		return nil
+	}
+
+	var vendored []string
+	for _, file := range found {
+		vendored = append(vendored, filepath.Base(file.Path))
+	}
+
+	var missing []string
+	for _, file := range upstream.Files {
+		if name := filepath.Base(file.Path); !contains(vendored, name) {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// resolveVendored finds a package by its vendored import path. The search for
+// its license stops at the vendor directory that it is in.
+func (r GopathResolver) resolveVendored(importPath string) (Location, error) {
+	location, err := r.resolve(importPath)
+	if err != nil {
+		return location, err
+	}
+
+	vendorRoot := importPath[:strings.LastIndex(importPath, "/vendor/")+len("/vendor")]
+	location.Root = filepath.Join(location.Root, filepath.FromSlash(vendorRoot))
+	location.Upstream = r.upstream(importPath)
+
+	return location, nil
+}
+
+// lookVendor looks for a package in the vendor directories of the current
+// directory and its parents within the GOPATH.
+func (r GopathResolver) lookVendor(importPath string) (Location, bool) {
+	src, ok := containingGopathSrc(r.gopaths, r.wd)
+	if !ok {
+		return Location{}, false
+	}
+
+	for dir := r.wd; dir != src && pathIsWithin(dir, src); dir = filepath.Dir(dir) {
+		vendored := filepath.Join(dir, "vendor", filepath.FromSlash(importPath))
+		if findPackage(vendored) == nil {
+			return Location{
+				Dir:      vendored,
+				Root:     filepath.Join(dir, "vendor"),
+				Upstream: r.upstream(importPath),
+			}, true
+		}
+	}
+
+	return Location{}, false
+}
+
+// upstream finds the copy of a vendored package in the GOPATH itself.
+func (r GopathResolver) upstream(importPath string) *Location {
+	location, err := r.resolve(VendorlessPath(importPath))
+	if err != nil {
+		return nil
+	}
+	return &location
+}
+
+// VendorlessPath is the import path that a vendored package is imported by,
+// such as "github.com/b/c" for "github.com/a/vendor/github.com/b/c".
+func VendorlessPath(importPath string) string {
+	if i := strings.LastIndex(importPath, "/vendor/"); i >= 0 {
+		return importPath[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(importPath, "vendor/")
+}
+
+func pathIsWithin(path string, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,200 @@
+package anderson
+
+import (
+	"embed"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MinimumMatchConfidence is the lowest similarity score at which a license
+// text is considered to be an instance of a template.
+const MinimumMatchConfidence = 0.8
+
+// The templates are taken from the SPDX License List. See
+// licenses/README.md for where they came from.
+//
+//go:embed licenses/*.txt
+var licenseTemplates embed.FS
+
+type LicenseMatch struct {
+	Name       string
+	Confidence float64
+}
+
+type licenseTemplate struct {
+	name     string
+	shingles []uint64
+}
+
+var (
+	templatesOnce sync.Once
+	templates     []licenseTemplate
+)
+
+var (
+	copyrightLine = regexp.MustCompile(`(?m)^\W*(copyright|\(c\)|©).*$`)
+	placeholder   = regexp.MustCompile(`<[^>\n]*>|\[[^\]\n]*\]`)
+	licenceWord   = regexp.MustCompile(`licen[cs]e`)
+	applyAppendix = regexp.MustCompile(`(?s)appendix:?\s+how\s+to\s+apply.*?limitations\s+under\s+the\s+license`)
+	word          = regexp.MustCompile(`[a-z0-9]+`)
+)
+
+// MatchLicenseText compares a license text against every embedded template
+// and returns the closest one. The second return value is false when nothing
+// scores at least MinimumMatchConfidence.
+func MatchLicenseText(text string) (LicenseMatch, bool) {
+	matches := MatchLicenseTemplates(text)
+	if len(matches) == 0 {
+		return LicenseMatch{}, false
+	}
+
+	return matches[0], matches[0].Confidence >= MinimumMatchConfidence
+}
+
+// MatchLicenseTemplates scores a license text against every embedded template
+// and returns the results best first.
+func MatchLicenseTemplates(text string) []LicenseMatch {
+	templatesOnce.Do(loadLicenseTemplates)
+
+	shingles := licenseShingles(text)
+	matches := make([]LicenseMatch, 0, len(templates))
+	for _, template := range templates {
+		matches = append(matches, LicenseMatch{
+			Name:       template.name,
+			Confidence: similarity(shingles, template.shingles),
+		})
+	}
+
+	sort.Sort(byConfidence(matches))
+
+	return matches
+}
+
+// LicenseNames returns the names of the licenses that anderson can detect.
+func LicenseNames() []string {
+	entries, err := licenseTemplates.ReadDir("licenses")
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if path.Ext(entry.Name()) == ".txt" {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".txt"))
+		}
+	}
+	return names
+}
+
+// LicenseTemplateText returns the text of the embedded template for a
+// license, or false if there is no template for it.
+func LicenseTemplateText(name string) (string, bool) {
+	text, err := licenseTemplates.ReadFile(path.Join("licenses", name+".txt"))
+	if err != nil {
+		return "", false
+	}
+	return string(text), true
+}
+
+func loadLicenseTemplates() {
+	entries, err := licenseTemplates.ReadDir("licenses")
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if path.Ext(entry.Name()) != ".txt" {
+			continue
+		}
+
+		text, err := licenseTemplates.ReadFile(path.Join("licenses", entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		templates = append(templates, licenseTemplate{
+			name:     strings.TrimSuffix(entry.Name(), ".txt"),
+			shingles: licenseShingles(string(text)),
+		})
+	}
+}
+
+// normalizeLicenseText reduces a license to its lower case words so that
+// formatting, punctuation, copyright holders and template placeholders do not
+// affect how it compares.
+func normalizeLicenseText(text string) []string {
+	text = strings.ToLower(text)
+	text = copyrightLine.ReplaceAllLiteralString(text, " ")
+	text = placeholder.ReplaceAllLiteralString(text, " ")
+	text = licenceWord.ReplaceAllLiteralString(text, "license")
+	text = strings.Replace(text, "&", " and ", -1)
+
+	text = applyAppendix.ReplaceAllLiteralString(text, " ")
+
+	return word.FindAllString(text, -1)
+}
+
+// licenseShingles hashes every run of three consecutive words in a license
+// and returns the distinct hashes sorted.
+func licenseShingles(text string) []uint64 {
+	words := normalizeLicenseText(text)
+
+	seen := map[uint64]bool{}
+	for i := 0; i+3 <= len(words); i++ {
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[i:i+3], " ")))
+		seen[hash.Sum64()] = true
+	}
+
+	shingles := make([]uint64, 0, len(seen))
+	for shingle := range seen {
+		shingles = append(shingles, shingle)
+	}
+	sort.Sort(uint64s(shingles))
+
+	return shingles
+}
+
+// similarity is the Dice coefficient of two sorted sets of shingles.
+func similarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	common := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+type byConfidence []LicenseMatch
+
+func (m byConfidence) Len() int      { return len(m) }
+func (m byConfidence) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m byConfidence) Less(i, j int) bool {
+	if m[i].Confidence != m[j].Confidence {
+		return m[i].Confidence > m[j].Confidence
+	}
+	return m[i].Name < m[j].Name
+}
+
+type uint64s []uint64
+
+func (u uint64s) Len() int           { return len(u) }
+func (u uint64s) Less(i, j int) bool { return u[i] < u[j] }
+func (u uint64s) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
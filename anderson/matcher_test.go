@@ -0,0 +1,20 @@
+package anderson
+
+import (
+	"path"
+	"testing"
+)
+
+func TestLicenseTemplatesIdentifyThemselves(t *testing.T) {
+	for _, name := range LicenseNames() {
+		text, err := licenseTemplates.ReadFile(path.Join("licenses", name+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expression, match := identifyLicenseText(text)
+		if want := templateLicenseName(name); expression.String() != want {
+			t.Errorf("%s is identified as %s (%s), expected %s", name, expression, match, want)
+		}
+	}
+}
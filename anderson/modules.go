@@ -0,0 +1,284 @@
+package anderson
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type GoEnv struct {
+	GOMOD      string
+	GOMODCACHE string
+	GOFLAGS    string
+}
+
+func LoadGoEnv() (GoEnv, error) {
+	var env GoEnv
+
+	cmd := exec.Command("go", "env", "-json", "GOMOD", "GOMODCACHE", "GOFLAGS")
+	output, err := cmd.Output()
+	if err != nil {
+		return env, err
+	}
+
+	err = json.Unmarshal(output, &env)
+	return env, err
+}
+
+// ModulesEnabled reports whether the go tool is running in module mode with
+// a main module. Module mode outside of a module (GOMOD=/dev/null) has
+// nothing for us to scan so it is treated like GOPATH mode.
+func (e GoEnv) ModulesEnabled() bool {
+	return e.GOMOD != "" && e.GOMOD != os.DevNull
+}
+
+func (e GoEnv) ModuleRoot() string {
+	return filepath.Dir(e.GOMOD)
+}
+
+func (e GoEnv) VendorEnabled() bool {
+	for _, flag := range strings.Fields(e.GOFLAGS) {
+		if strings.HasPrefix(flag, "-mod=") {
+			return flag == "-mod=vendor"
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(e.ModuleRoot(), "vendor", "modules.txt"))
+	return err == nil
+}
+
+type Module struct {
+	Path    string
+	Version string
+	Main    bool
+	Dir     string
+	Replace *Module
+}
+
+type ModuleResolver struct {
+	env     GoEnv
+	modules []Module
+}
+
+func NewModuleResolver(env GoEnv) (ModuleResolver, error) {
+	resolver := ModuleResolver{env: env}
+
+	var modules []Module
+	var err error
+	if env.VendorEnabled() {
+		modules, err = loadVendoredModules(filepath.Join(env.ModuleRoot(), "vendor", "modules.txt"))
+	} else {
+		modules, err = loadModules()
+	}
+	if err != nil {
+		return resolver, err
+	}
+
+	// Longest paths first so that nested modules win over their parents.
+	sort.Sort(sort.Reverse(byModulePath(modules)))
+	resolver.modules = modules
+
+	return resolver, nil
+}
+
+func (r ModuleResolver) Resolve(importPath string) (Location, error) {
+	for _, module := range r.modules {
+		if module.Main {
+			continue
+		}
+
+		if importPath != module.Path && !strings.HasPrefix(importPath, module.Path+"/") {
+			continue
+		}
+
+		root := r.moduleDir(module)
+		if root == "" {
+			return Location{}, errors.New("could not find module " + module.Path)
+		}
+
+		dir := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(importPath, module.Path)))
+		if err := findPackage(dir); err != nil {
+			return Location{}, err
+		}
+
+		location := Location{
+			Dir:            dir,
+			Root:           root,
+			RootImportPath: module.Path,
+			Version:        module.Version,
+		}
+
+		if r.env.VendorEnabled() {
+			location.Upstream = r.upstream(module, importPath)
+		}
+
+		return location, nil
+	}
+
+	return Location{}, errors.New("could not find package in any module")
+}
+
+// upstream finds the copy of a vendored package in the module cache or in
+// the directory that its module is replaced with.
+func (r ModuleResolver) upstream(module Module, importPath string) *Location {
+	root := r.downloadedModuleDir(module)
+	if root == "" {
+		return nil
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(importPath, module.Path)))
+	if err := findPackage(dir); err != nil {
+		return nil
+	}
+
+	return &Location{
+		Dir:            dir,
+		Root:           root,
+		RootImportPath: module.Path,
+		Version:        module.Version,
+	}
+}
+
+func (r ModuleResolver) moduleDir(module Module) string {
+	if r.env.VendorEnabled() {
+		return filepath.Join(r.env.ModuleRoot(), "vendor", filepath.FromSlash(module.Path))
+	}
+
+	return r.downloadedModuleDir(module)
+}
+
+func (r ModuleResolver) downloadedModuleDir(module Module) string {
+	if module.Dir != "" {
+		return module.Dir
+	}
+
+	if module.Replace != nil {
+		if module.Replace.Version == "" {
+			return filepath.Join(r.env.ModuleRoot(), module.Replace.Path)
+		}
+		module = *module.Replace
+	}
+
+	if r.env.GOMODCACHE == "" || module.Version == "" {
+		return ""
+	}
+
+	return filepath.Join(r.env.GOMODCACHE, escapeModulePath(module.Path)+"@"+module.Version)
+}
+
+func loadModules() ([]Module, error) {
+	cmd := exec.Command("go", "list", "-m", "-json", "all")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	decoder := json.NewDecoder(stdout)
+	for {
+		var module Module
+		err = decoder.Decode(&module)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Wait()
+			return nil, err
+		}
+		modules = append(modules, module)
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	return modules, nil
+}
+
+// loadModuleTools lists the packages named by the tool directives in the
+// main module's go.mod.
+func loadModuleTools() ([]string, error) {
+	output, err := exec.Command("go", "mod", "edit", "-json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var goMod struct {
+		Tool []struct {
+			Path string
+		}
+	}
+	if err := json.Unmarshal(output, &goMod); err != nil {
+		return nil, err
+	}
+
+	var tools []string
+	for _, tool := range goMod.Tool {
+		tools = append(tools, tool.Path)
+	}
+
+	return tools, nil
+}
+
+// loadVendoredModules reads the module list that `go mod vendor` records in
+// vendor/modules.txt since `go list -m all` refuses to run in vendor mode.
+func loadVendoredModules(path string) ([]Module, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var modules []Module
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "#" {
+			continue
+		}
+
+		module := Module{Path: fields[1]}
+		if len(fields) > 2 && fields[2] != "=>" {
+			module.Version = fields[2]
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, scanner.Err()
+}
+
+// escapeModulePath applies the module cache's case encoding where every
+// upper case letter is replaced by an exclamation mark and its lower case
+// equivalent.
+func escapeModulePath(path string) string {
+	var escaped strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			escaped.WriteRune('!')
+			escaped.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+type byModulePath []Module
+
+func (m byModulePath) Len() int           { return len(m) }
+func (m byModulePath) Less(i, j int) bool { return m[i].Path < m[j].Path }
+func (m byModulePath) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
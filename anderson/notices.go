@@ -0,0 +1,267 @@
+package anderson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const noticesRule = "================================================================================"
+
+// Attribution is a license text or NOTICE file along with every dependency
+// that ships it.
+type Attribution struct {
+	Title        string
+	Notice       bool
+	Dependencies []string
+	Text         string
+}
+
+// Notices is an attribution document for the dependencies in a report.
+// Identical texts only appear once. Missing lists the dependencies that no
+// license text could be found for.
+type Notices struct {
+	RootName     string
+	Attributions []Attribution
+	Missing      []string
+}
+
+// NewNotices reads the license files of every dependency in a report along
+// with any NOTICE files next to them. Everything is kept in the order of the
+// report so the document only changes when the dependencies do. Only the
+// dependencies that ship are included unless allScopes is set, in which case
+// the others are marked with their scope.
+func NewNotices(rootName string, report Report, allScopes bool) (Notices, error) {
+	notices := Notices{RootName: rootName}
+	seen := map[string]int{}
+
+	attribute := func(title string, notice bool, dependency string, text string) {
+		normalized := normalizeNoticeText(text)
+		key := fmt.Sprintf("%t\x00%s", notice, normalized)
+		if i, ok := seen[key]; ok {
+			if !contains(notices.Attributions[i].Dependencies, dependency) {
+				notices.Attributions[i].Dependencies = append(notices.Attributions[i].Dependencies, dependency)
+			}
+			return
+		}
+
+		seen[key] = len(notices.Attributions)
+		notices.Attributions = append(notices.Attributions, Attribution{
+			Title:        title,
+			Notice:       notice,
+			Dependencies: []string{dependency},
+			Text:         normalized,
+		})
+	}
+
+	for _, dependency := range report.Dependencies {
+		if !dependency.Scope.Ships() && !allScopes {
+			continue
+		}
+
+		name := dependency.LicensePath
+		if dependency.Version != "" {
+			name += " " + dependency.Version
+		}
+		if !dependency.Scope.Ships() {
+			name += fmt.Sprintf(" [%s]", dependency.Scope)
+		}
+
+		if len(dependency.LicenseFiles) == 0 {
+			if !contains(notices.Missing, name) {
+				notices.Missing = append(notices.Missing, name)
+			}
+			continue
+		}
+
+		found := append(append([]LicenseFile{}, dependency.LicenseFiles...), dependency.Nested...)
+		for _, file := range found {
+			text, err := licenseText(file)
+			if err != nil {
+				return Notices{}, err
+			}
+			attribute(file.License.String(), false, name, text)
+		}
+
+		// License files can be in subdirectories, like a REUSE LICENSES
+		// directory, so the NOTICE files are looked for in the shallowest.
+		dir := filepath.Dir(dependency.LicenseFiles[0].Path)
+		for _, file := range dependency.LicenseFiles[1:] {
+			if parent := filepath.Dir(file.Path); len(parent) < len(dir) {
+				dir = parent
+			}
+		}
+
+		files, err := noticeFiles(dir)
+		if err != nil {
+			return Notices{}, err
+		}
+
+		for _, file := range files {
+			if isLicenseFile(dependency.LicenseFiles, filepath.Join(dir, file)) {
+				continue
+			}
+
+			text, err := ioutil.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				return Notices{}, err
+			}
+			attribute("NOTICE", true, name, string(text))
+		}
+	}
+
+	return notices, nil
+}
+
+// licenseText is the text of a license file. Only the header of a Go file
+// is part of its license.
+func licenseText(file LicenseFile) (string, error) {
+	if file.Header {
+		return sourceHeader(file.Path)
+	}
+
+	text, err := ioutil.ReadFile(file.Path)
+	return string(text), err
+}
+
+func (n Notices) WriteText(w io.Writer) error {
+	var buffer bytes.Buffer
+
+	fmt.Fprintln(&buffer, "THIRD PARTY NOTICES")
+	fmt.Fprintln(&buffer)
+	fmt.Fprintf(&buffer, "%s uses the following third party software. Their licenses and notices are\nreproduced below.\n", n.RootName)
+
+	for _, attribution := range n.Attributions {
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, noticesRule)
+		fmt.Fprintln(&buffer, attribution.Title)
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, "Used by:")
+		for _, dependency := range attribution.Dependencies {
+			fmt.Fprintf(&buffer, "  %s\n", dependency)
+		}
+		fmt.Fprintln(&buffer, noticesRule)
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, attribution.Text)
+	}
+
+	if len(n.Missing) > 0 {
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, noticesRule)
+		fmt.Fprintln(&buffer, "No license text was found for:")
+		for _, dependency := range n.Missing {
+			fmt.Fprintf(&buffer, "  %s\n", dependency)
+		}
+		fmt.Fprintln(&buffer, noticesRule)
+	}
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+func (n Notices) WriteMarkdown(w io.Writer) error {
+	var buffer bytes.Buffer
+
+	fmt.Fprintln(&buffer, "# Third Party Notices")
+	fmt.Fprintln(&buffer)
+	fmt.Fprintf(&buffer, "%s uses the following third party software. Their licenses and notices are reproduced below.\n", n.RootName)
+
+	for _, attribution := range n.Attributions {
+		fence := markdownFence(attribution.Text)
+
+		fmt.Fprintln(&buffer)
+		fmt.Fprintf(&buffer, "## %s\n", attribution.Title)
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, "Used by:")
+		fmt.Fprintln(&buffer)
+		for _, dependency := range attribution.Dependencies {
+			fmt.Fprintf(&buffer, "- %s\n", dependency)
+		}
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, fence)
+		fmt.Fprintln(&buffer, attribution.Text)
+		fmt.Fprintln(&buffer, fence)
+	}
+
+	if len(n.Missing) > 0 {
+		fmt.Fprintln(&buffer)
+		fmt.Fprintln(&buffer, "## No license text found")
+		fmt.Fprintln(&buffer)
+		for _, dependency := range n.Missing {
+			fmt.Fprintf(&buffer, "- %s\n", dependency)
+		}
+	}
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+func isLicenseFile(files []LicenseFile, path string) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// noticeFiles lists the NOTICE files in a directory, such as the ones that
+// the Apache license asks to be passed on.
+func noticeFiles(path string) ([]string, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := strings.ToLower(entry.Name())
+		if name == "notice" || strings.HasPrefix(name, "notice.") {
+			files = append(files, entry.Name())
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// normalizeNoticeText tidies up line endings and trailing whitespace so that
+// copies of the same text are recognised as identical.
+func normalizeNoticeText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// markdownFence returns a code fence longer than any run of backticks in the
+// text so that the text cannot close it early.
+func markdownFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+
+	if longest < 3 {
+		longest = 2
+	}
+
+	return strings.Repeat("`", longest+1)
+}
@@ -0,0 +1,68 @@
+package anderson
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Override declares the license of a dependency that anderson cannot detect,
+// such as one that only states it in its README or on its website. The
+// license is asserted rather than found, so Reference should say where it
+// was stated.
+type Override struct {
+	License   string `yaml:"license" json:"license"`
+	Reference string `yaml:"reference,omitempty" json:"reference,omitempty"`
+}
+
+// override finds the override for a package at a version and returns it
+// along with its key. Overrides are keyed by an import path that also covers
+// the packages below it, optionally followed by @version to only apply at
+// that version. The longest path wins and a versioned entry wins over one for
+// any version of the same path.
+func (c Config) override(importPath string, version string) (string, Override, bool) {
+	var found, foundKey string
+	var override Override
+	var foundVersioned bool
+
+	for key, candidate := range c.Overrides {
+		overridePath, overrideVersion := splitOverrideKey(key)
+		if overrideVersion != "" && overrideVersion != version {
+			continue
+		}
+
+		if importPath != overridePath && !strings.HasPrefix(importPath, overridePath+"/") {
+			continue
+		}
+
+		versioned := overrideVersion != ""
+		if found == "" || len(overridePath) > len(found) || len(overridePath) == len(found) && versioned && !foundVersioned {
+			found, foundKey, override, foundVersioned = overridePath, key, candidate, versioned
+		}
+	}
+
+	return foundKey, override, found != ""
+}
+
+func splitOverrideKey(key string) (string, string) {
+	if index := strings.LastIndex(key, "@"); index >= 0 {
+		return key[:index], key[index+1:]
+	}
+	return key, ""
+}
+
+// overrideDir is the directory of the package that an override is for,
+// which importPath at dir is in. The directory is not allowed to leave root.
+func overrideDir(dir string, root string, importPath string, overridePath string) string {
+	below := strings.Count(strings.TrimPrefix(importPath, overridePath), "/")
+
+	overridden := dir
+	for i := 0; i < below; i++ {
+		overridden = filepath.Dir(overridden)
+	}
+
+	if !pathIsWithin(overridden, root) {
+		return dir
+	}
+
+	return overridden
+}
@@ -0,0 +1,35 @@
+package anderson
+
+import "sync"
+
+// Parallel calls work with every index below count, running at most jobs of
+// the calls at once. It returns when all of them have. Each call should only
+// write to its own index of any results so that the results come out in the
+// same order whatever the number of jobs.
+func Parallel(jobs int, count int, work func(i int)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > count {
+		jobs = count
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+}
@@ -0,0 +1,74 @@
+package anderson
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+const benchmarkDependencies = 2000
+
+// BenchmarkClassifyDependencies resolves and classifies every dependency in a
+// generated GOPATH one at a time and with a pool of workers.
+func BenchmarkClassifyDependencies(b *testing.B) {
+	gopath := b.TempDir()
+	importPaths := generateDependencies(b, gopath, benchmarkDependencies)
+
+	b.Setenv("GOPATH", gopath)
+	b.Chdir(gopath)
+
+	resolver, err := NewGopathResolver()
+	if err != nil {
+		b.Fatal(err)
+	}
+	classifier := LicenseClassifier{Config: Config{Whitelist: []string{"MIT"}}}
+
+	for _, jobs := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				classifications := make([]Classification, len(importPaths))
+				Parallel(jobs, len(importPaths), func(i int) {
+					location, err := resolver.Resolve(importPaths[i])
+					if err != nil {
+						b.Error(err)
+						return
+					}
+
+					classifications[i], err = classifier.Classify(location.Dir, location.Root, importPaths[i])
+					if err != nil {
+						b.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// generateDependencies writes count packages to a GOPATH, a few directories
+// below their license so that the classifier has to search for it. The
+// licenses take turns between a few of the embedded templates so that both
+// key phrases and template matching are used.
+func generateDependencies(b *testing.B, gopath string, count int) []string {
+	var licenses []string
+	for _, name := range []string{"MIT", "Apache-2.0", "BSD-3-Clause", "ISC"} {
+		text, err := licenseTemplates.ReadFile("licenses/" + name + ".txt")
+		if err != nil {
+			b.Fatal(err)
+		}
+		licenses = append(licenses, string(text))
+	}
+
+	var importPaths []string
+	for i := 0; i < count; i++ {
+		project := fmt.Sprintf("example.com/owner%d/project%d", i%50, i)
+		importPath := project + "/internal/pkg"
+		dir := filepath.Join(gopath, "src", filepath.FromSlash(importPath))
+
+		writeBenchmarkFile(b, filepath.Join(gopath, "src", filepath.FromSlash(project), "LICENSE"), licenses[i%len(licenses)])
+		writeBenchmarkFile(b, filepath.Join(dir, "pkg.go"), "package pkg\n")
+
+		importPaths = append(importPaths, importPath)
+	}
+
+	return importPaths
+}
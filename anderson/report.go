@@ -0,0 +1,226 @@
+package anderson
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Dependency is the verdict for a single dependency. LicensePath is the
+// import path of the directory that the license was found in, which may be a
+// parent of ImportPath. Asserted dependencies took their license from an
+// override instead of finding it. Unvendored lists the license files that
+// upstream has but that were left out when the dependency was vendored.
+// Nested is the files that a deep scan found other licenses in. LicenseDir
+// is the directory on disk that LicensePath refers to.
+type Dependency struct {
+	ImportPath     string        `json:"import_path"`
+	Version        string        `json:"version,omitempty"`
+	Scope          Scope         `json:"scope"`
+	LicensePath    string        `json:"license_path"`
+	LicenseDir     string        `json:"-"`
+	License        string        `json:"license"`
+	ElectedLicense string        `json:"elected_license"`
+	Asserted       bool          `json:"asserted"`
+	LicenseFiles   []LicenseFile `json:"license_files"`
+	Unvendored     []string      `json:"unvendored_license_files,omitempty"`
+	Nested         []LicenseFile `json:"nested_license_files,omitempty"`
+	Status         LicenseStatus `json:"status"`
+	FailsBuild     bool          `json:"fails_build"`
+	Rule           *Rule         `json:"rule"`
+	Override       *Override     `json:"override,omitempty"`
+	Baselined      bool          `json:"baselined"`
+	ImportedBy     [][]string    `json:"imported_by,omitempty"`
+	Trace          []string      `json:"trace,omitempty"`
+}
+
+func NewDependency(importPath string, licensePath string, classification Classification) Dependency {
+	return Dependency{
+		ImportPath:     importPath,
+		LicensePath:    licensePath,
+		LicenseDir:     classification.Path,
+		License:        classification.License.String(),
+		ElectedLicense: classification.Elected.String(),
+		LicenseFiles:   classification.Files,
+		Nested:         classification.Nested,
+		Status:         classification.Status,
+		FailsBuild:     classification.Status.FailsBuild(),
+		Asserted:       classification.Override != nil,
+		Rule:           classification.Rule,
+		Override:       classification.Override,
+		Trace:          classification.Trace,
+	}
+}
+
+// MergeDependencies combines the verdicts for two packages whose license was
+// found in the same directory, such as sibling packages of one dependency.
+// The more severe verdict is kept, so a bad license found in either package
+// decides, and it takes the wider of their scopes and the nested license
+// files of both.
+func MergeDependencies(a Dependency, b Dependency) Dependency {
+	merged, other := a, b
+	if b.Status.severity() > a.Status.severity() || b.Status.severity() == a.Status.severity() && b.Scope.WiderThan(a.Scope) {
+		merged, other = b, a
+	}
+
+	if other.Scope.WiderThan(merged.Scope) {
+		merged.Scope = other.Scope
+	}
+	merged.FailsBuild = merged.FailsBuild || other.FailsBuild
+
+	merged.Nested = append([]LicenseFile{}, merged.Nested...)
+	for _, file := range other.Nested {
+		if !isLicenseFile(merged.Nested, file.Path) {
+			merged.Nested = append(merged.Nested, file)
+		}
+	}
+	sort.Sort(byLicenseFilePath(merged.Nested))
+	if len(merged.Nested) == 0 {
+		merged.Nested = nil
+	}
+
+	return merged
+}
+
+// LicenseFileName is the slash separated path of one of the dependency's
+// license files relative to the directory its license was found in.
+func (d Dependency) LicenseFileName(file LicenseFile) string {
+	if d.LicenseDir == "" {
+		return filepath.Base(file.Path)
+	}
+
+	name, err := filepath.Rel(d.LicenseDir, file.Path)
+	if err != nil {
+		return filepath.Base(file.Path)
+	}
+	return filepath.ToSlash(name)
+}
+
+type Report struct {
+	Dependencies      []Dependency          `json:"dependencies"`
+	Totals            map[LicenseStatus]int `json:"totals"`
+	Total             int                   `json:"total"`
+	Passed            bool                  `json:"passed"`
+	StaleBaseline     []BaselineEntry       `json:"stale_baseline,omitempty"`
+	ExpiredExceptions []Exception           `json:"expired_exceptions,omitempty"`
+	PackageErrors     []PackageError        `json:"package_errors,omitempty"`
+}
+
+// NewReport sorts the dependencies by the path their license was found at
+// and works out the totals and the overall result.
+func NewReport(dependencies []Dependency) Report {
+	sort.Sort(byLicensePath(dependencies))
+
+	report := Report{
+		Dependencies: dependencies,
+		Totals:       map[LicenseStatus]int{},
+		Total:        len(dependencies),
+		Passed:       true,
+	}
+
+	for _, dependency := range dependencies {
+		report.Totals[dependency.Status]++
+		if dependency.FailsBuild {
+			report.Passed = false
+		}
+	}
+
+	return report
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// StatusGroup is the dependencies in a report that share a status.
+type StatusGroup struct {
+	Status       LicenseStatus
+	Dependencies []Dependency
+}
+
+// Groups splits the dependencies up by their status. The statuses that are
+// the most trouble come first and the dependencies in each group stay in the
+// order of the report.
+func (r Report) Groups() []StatusGroup {
+	statuses := []LicenseStatus{}
+	for status := range licenseStatusNames {
+		statuses = append(statuses, status)
+	}
+	sort.Sort(bySeverity(statuses))
+
+	var groups []StatusGroup
+	for _, status := range statuses {
+		group := StatusGroup{Status: status}
+		for _, dependency := range r.Dependencies {
+			if dependency.Status == status {
+				group.Dependencies = append(group.Dependencies, dependency)
+			}
+		}
+
+		if len(group.Dependencies) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// LicenseCount is the number of dependencies that have a license.
+type LicenseCount struct {
+	License string
+	Count   int
+}
+
+// LicenseCounts counts the dependencies under each license, most common
+// first.
+func (r Report) LicenseCounts() []LicenseCount {
+	counts := map[string]int{}
+	for _, dependency := range r.Dependencies {
+		counts[dependency.License]++
+	}
+
+	licenseCounts := []LicenseCount{}
+	for license, count := range counts {
+		licenseCounts = append(licenseCounts, LicenseCount{License: license, Count: count})
+	}
+	sort.Sort(byCount(licenseCounts))
+
+	return licenseCounts
+}
+
+// Failures returns the dependencies that fail the build.
+func (r Report) Failures() []Dependency {
+	var failures []Dependency
+	for _, dependency := range r.Dependencies {
+		if dependency.FailsBuild {
+			failures = append(failures, dependency)
+		}
+	}
+	return failures
+}
+
+type byLicensePath []Dependency
+
+func (d byLicensePath) Len() int           { return len(d) }
+func (d byLicensePath) Less(i, j int) bool { return d[i].LicensePath < d[j].LicensePath }
+func (d byLicensePath) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+type bySeverity []LicenseStatus
+
+func (s bySeverity) Len() int           { return len(s) }
+func (s bySeverity) Less(i, j int) bool { return s[i].severity() > s[j].severity() }
+func (s bySeverity) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type byCount []LicenseCount
+
+func (c byCount) Len() int      { return len(c) }
+func (c byCount) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCount) Less(i, j int) bool {
+	if c[i].Count != c[j].Count {
+		return c[i].Count > c[j].Count
+	}
+	return c[i].License < c[j].License
+}
@@ -0,0 +1,69 @@
+package anderson
+
+import "strings"
+
+// Scope is how a dependency is used by the project. Runtime dependencies are
+// built into the project's packages, test dependencies are only imported by
+// its tests and tool dependencies are only needed by the tools that its
+// go.mod lists.
+type Scope string
+
+const (
+	ScopeRuntime Scope = "runtime"
+	ScopeTest    Scope = "test"
+	ScopeTool    Scope = "tool"
+)
+
+var scopeWidths = map[Scope]int{
+	ScopeTool:    1,
+	ScopeTest:    2,
+	ScopeRuntime: 3,
+}
+
+// WiderThan reports whether more of the project relies on s than on other. A
+// dependency that is used in several ways takes the widest of them.
+func (s Scope) WiderThan(other Scope) bool {
+	return scopeWidths[s] > scopeWidths[other]
+}
+
+// Ships reports whether dependencies in the scope end up in the project's
+// binaries.
+func (s Scope) Ships() bool {
+	return s == ScopeRuntime
+}
+
+// Scopes is the scope of every package in an import graph.
+type Scopes map[string]Scope
+
+// Of returns the widest scope of importPath and the packages below it.
+// Packages that are not in the graph, such as the ones read from STDIN, are
+// assumed to be runtime dependencies.
+func (s Scopes) Of(importPath string) Scope {
+	var scope Scope
+	for pkg, pkgScope := range s {
+		if pkg == importPath || strings.HasPrefix(pkg, importPath+"/") {
+			if pkgScope.WiderThan(scope) {
+				scope = pkgScope
+			}
+		}
+	}
+
+	if scope == "" {
+		return ScopeRuntime
+	}
+
+	return scope
+}
+
+// rootScope is the scope of everything that a root of the import graph
+// imports.
+func rootScope(root string) Scope {
+	switch {
+	case strings.HasSuffix(root, testRootSuffix):
+		return ScopeTest
+	case strings.HasSuffix(root, toolRootSuffix):
+		return ScopeTool
+	default:
+		return ScopeRuntime
+	}
+}
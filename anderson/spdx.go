@@ -0,0 +1,565 @@
+package anderson
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	spdxVersion     = "SPDX-2.3"
+	spdxNoAssertion = "NOASSERTION"
+	spdxNone        = "NONE"
+)
+
+// spdxLicenseIdentifiers maps the license names that anderson reports but
+// that are not SPDX license identifiers to their SPDX equivalent.
+var spdxLicenseIdentifiers = map[string]string{
+	"NewBSD":                           "BSD-3-Clause",
+	"FreeBSD":                          "BSD-2-Clause",
+	"BSD-0-Clause":                     "0BSD",
+	"Business-Source-License-1.1":      "BUSL-1.1",
+	"Apache-with-LLVM-Exception":       "Apache-2.0 WITH LLVM-exception",
+	"GPL-2.0-with-classpath-exception": "GPL-2.0-only WITH Classpath-exception-2.0",
+	"GPL-2.0-with-GCC-exception":       "GPL-2.0-only WITH GCC-exception-2.0",
+	"GPL-2.0-with-autoconf-exception":  "GPL-2.0-only WITH Autoconf-exception-2.0",
+	"GPL-2.0-with-bison-exception":     "GPL-2.0-only WITH Bison-exception-2.2",
+	"GPL-2.0-with-font-exception":      "GPL-2.0-only WITH Font-exception-2.0",
+	"GPL-3.0-with-GCC-exception":       "GPL-3.0-only WITH GCC-exception-3.1",
+	"GPL-3.0-with-autoconf-exception":  "GPL-3.0-only WITH Autoconf-exception-3.0",
+	"GPL-3.0-with-bison-exception":     "GPL-3.0-only WITH Bison-exception-2.2",
+}
+
+// nonSPDXLicenses are the names of license templates that are not on the
+// SPDX License List.
+var nonSPDXLicenses = []string{
+	"ANTLR", "Apache-with-Runtime-Exception", "BLAS", "BSD-2-Clause-Flex",
+	"BSD-3-Clause-OpenMPI", "BSD-FatFs", "BSD-No-Other-Rights", "BSD-Rice",
+	"BabelstoneIDS", "BeOpen", "Bitstream", "Boost-original",
+	"CERN-OHL-WR-v2", "CERN-OHL-v1.2", "CLIPS", "Commons-Clause", "FFT2D",
+	"Facebook-2-Clause", "Facebook-3-Clause", "GD-Graphic-Library",
+	"GIF-Encoder", "GNU-All-permissive-Copying-License", "GUST-Font-License",
+	"HDF5", "HTK", "IDA", "InnerNet", "JTidy", "JasPer", "Khronos", "LZMA",
+	"Lil-1.0", "NCBI", "NREL", "OROMatcher", "Open-Game-License-1.0a",
+	"OpenLDAP", "OpenVision", "Oracle-Open-Symphony", "PIL", "PNG", "PPP",
+	"Python-2.0-complete", "RSA", "Rijndael-3.0", "SPL-SQRT-FLOOR", "SQLite",
+	"Sflow", "SunPro", "TPM-2", "UFL-1.0", "VMAC", "WordNet-3.0",
+	"X11-Lucent", "XZ", "aopalliance", "bzip2", "cURL", "dso", "geant4",
+	"getopt", "hdparm", "pffft", "re2c", "tcl_tk", "unicode_org",
+	"wxWindows-3.1",
+}
+
+var (
+	spdxIDInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+	spdxLicenseRef          = regexp.MustCompile(`LicenseRef-[A-Za-z0-9.-]+`)
+)
+
+// SPDXDocument is an SPDX 2.3 document describing the dependencies of a
+// package and the licenses they were found to have.
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	Packages          []SPDXPackage      `json:"packages"`
+	Files             []SPDXFile         `json:"files,omitempty"`
+	ExtractedLicenses []SPDXExtracted    `json:"hasExtractedLicensingInfos,omitempty"`
+	Relationships     []SPDXRelationship `json:"relationships"`
+}
+
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type SPDXPackage struct {
+	Name                 string                `json:"name"`
+	SPDXID               string                `json:"SPDXID"`
+	VersionInfo          string                `json:"versionInfo,omitempty"`
+	DownloadLocation     string                `json:"downloadLocation"`
+	FilesAnalyzed        bool                  `json:"filesAnalyzed"`
+	VerificationCode     *SPDXVerificationCode `json:"packageVerificationCode,omitempty"`
+	LicenseConcluded     string                `json:"licenseConcluded"`
+	LicenseDeclared      string                `json:"licenseDeclared"`
+	LicenseInfoFromFiles []string              `json:"licenseInfoFromFiles,omitempty"`
+	LicenseComments      string                `json:"licenseComments,omitempty"`
+	CopyrightText        string                `json:"copyrightText"`
+	ExternalRefs         []SPDXExternalRef     `json:"externalRefs,omitempty"`
+}
+
+type SPDXVerificationCode struct {
+	Value string `json:"packageVerificationCodeValue"`
+}
+
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type SPDXFile struct {
+	FileName           string         `json:"fileName"`
+	SPDXID             string         `json:"SPDXID"`
+	Checksums          []SPDXChecksum `json:"checksums"`
+	LicenseConcluded   string         `json:"licenseConcluded"`
+	LicenseInfoInFiles []string       `json:"licenseInfoInFiles"`
+	CopyrightText      string         `json:"copyrightText"`
+}
+
+// SPDXExtracted is the text of a license that is not on the SPDX License
+// List, for the LicenseRef that stands in for it.
+type SPDXExtracted struct {
+	LicenseID     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name"`
+}
+
+type SPDXChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type SPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// NewSPDXDocument describes a report as an SPDX document. The root package
+// is the package that was scanned and it DEPENDS_ON a package for every
+// runtime dependency. Test and tool dependencies are a TEST_DEPENDENCY_OF or
+// DEV_TOOL_OF the root package instead. Each dependency CONTAINS the license
+// files that were read, and its verification code covers every file in its
+// directory. Licenses that are not on the SPDX License List are given as a
+// LicenseRef along with their text.
+func NewSPDXDocument(rootName string, report Report, created time.Time) SPDXDocument {
+	document := SPDXDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              rootName,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/anderson/%s-%s", rootName, randomIdentifier()),
+		CreationInfo: SPDXCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: anderson"},
+		},
+	}
+
+	root := SPDXPackage{
+		Name:             rootName,
+		SPDXID:           "SPDXRef-RootPackage",
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+	}
+	document.Packages = append(document.Packages, root)
+	document.Relationships = append(document.Relationships, SPDXRelationship{
+		SPDXElementID:      document.SPDXID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: root.SPDXID,
+	})
+
+	for i, dependency := range report.Dependencies {
+		pkg := SPDXPackage{
+			Name:             dependency.LicensePath,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d-%s", i+1, spdxIDInvalidCharacters.ReplaceAllString(dependency.LicensePath, "-")),
+			VersionInfo:      dependency.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxLicenseExpression(dependency.ElectedLicense),
+			LicenseDeclared:  spdxLicenseExpression(dependency.License),
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []SPDXExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  PackageURL(dependency.LicensePath, dependency.Version),
+			}},
+		}
+		if len(dependency.LicenseFiles) == 0 && dependency.License == unknownLicense.License {
+			pkg.LicenseConcluded = spdxNone
+			pkg.LicenseDeclared = spdxNone
+		}
+		if dependency.Asserted {
+			pkg.LicenseDeclared = spdxNoAssertion
+			pkg.LicenseComments = spdxAssertionComment(dependency.Override)
+		}
+
+		var licenseFiles []LicenseFile
+		if len(dependency.LicenseFiles) > 0 && dependency.LicenseDir != "" {
+			if code, err := spdxVerificationCode(dependency.LicenseDir); err == nil {
+				pkg.FilesAnalyzed = true
+				pkg.VerificationCode = &SPDXVerificationCode{Value: code}
+				licenseFiles = dependency.LicenseFiles
+			}
+		}
+
+		var files []SPDXRelationship
+		for j, licenseFile := range licenseFiles {
+			file := SPDXFile{
+				FileName: "./" + dependency.LicenseFileName(licenseFile),
+				SPDXID:   fmt.Sprintf("SPDXRef-File-%d-%d", i+1, j+1),
+				Checksums: []SPDXChecksum{
+					{Algorithm: "SHA1", ChecksumValue: licenseFile.SHA1},
+					{Algorithm: "SHA256", ChecksumValue: licenseFile.SHA256},
+				},
+				LicenseConcluded:   spdxLicenseExpression(licenseFile.License.String()),
+				LicenseInfoInFiles: spdxLicenseInfo(licenseFile.License),
+				CopyrightText:      spdxNoAssertion,
+			}
+			document.Files = append(document.Files, file)
+			files = append(files, SPDXRelationship{
+				SPDXElementID:      pkg.SPDXID,
+				RelationshipType:   "CONTAINS",
+				RelatedSPDXElement: file.SPDXID,
+			})
+			for _, info := range file.LicenseInfoInFiles {
+				if !contains(pkg.LicenseInfoFromFiles, info) {
+					pkg.LicenseInfoFromFiles = append(pkg.LicenseInfoFromFiles, info)
+				}
+			}
+		}
+
+		document.Packages = append(document.Packages, pkg)
+		document.Relationships = append(document.Relationships, spdxDependencyRelationship(root, pkg, dependency.Scope))
+		document.Relationships = append(document.Relationships, files...)
+	}
+
+	document.ExtractedLicenses = spdxExtractedLicenses(document)
+
+	return document
+}
+
+// spdxVerificationCode works out the verification code of the package in
+// dir from every file in it. Hidden directories and the vendor directory,
+// whose packages are described on their own, are left out.
+func spdxVerificationCode(dir string) (string, error) {
+	var checksums []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha1.Sum(text)
+		checksums = append(checksums, hex.EncodeToString(sum[:]))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(checksums)
+
+	sum := sha1.Sum([]byte(strings.Join(checksums, "")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// spdxExtractedLicenses gives the text of every LicenseRef that the document
+// uses. The ones that anderson made up for its own templates have the
+// template's text, and ones that came from the dependencies say that the
+// text was not kept.
+func spdxExtractedLicenses(document SPDXDocument) []SPDXExtracted {
+	var expressions []string
+	for _, pkg := range document.Packages {
+		expressions = append(expressions, pkg.LicenseConcluded, pkg.LicenseDeclared)
+		expressions = append(expressions, pkg.LicenseInfoFromFiles...)
+	}
+	for _, file := range document.Files {
+		expressions = append(expressions, file.LicenseConcluded)
+		expressions = append(expressions, file.LicenseInfoInFiles...)
+	}
+
+	var extracted []SPDXExtracted
+	seen := map[string]bool{}
+	for _, expression := range expressions {
+		for _, id := range spdxLicenseRef.FindAllString(expression, -1) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			license := SPDXExtracted{
+				LicenseID:     id,
+				ExtractedText: "The text of this license was not recorded by anderson.",
+				Name:          strings.TrimPrefix(id, "LicenseRef-"),
+			}
+			for _, name := range nonSPDXLicenses {
+				if spdxLicenseRefID(name) != id {
+					continue
+				}
+				license.Name = name
+				if text, ok := LicenseTemplateText(name); ok {
+					license.ExtractedText = text
+				}
+			}
+			extracted = append(extracted, license)
+		}
+	}
+
+	return extracted
+}
+
+// spdxLicenseRefID is the LicenseRef that stands in for a license that is not
+// on the SPDX License List.
+func spdxLicenseRefID(license string) string {
+	return "LicenseRef-" + spdxIDInvalidCharacters.ReplaceAllString(license, "-")
+}
+
+// spdxAssertionComment explains where an asserted license came from, since
+// it was concluded without any evidence in the package itself.
+func spdxAssertionComment(override *Override) string {
+	comment := "The license was asserted by an override in the anderson config."
+	if override != nil && override.Reference != "" {
+		comment += " Reference: " + override.Reference
+	}
+	return comment
+}
+
+// spdxDependencyRelationship relates a dependency to the root package in the
+// way that suits its scope.
+func spdxDependencyRelationship(root SPDXPackage, pkg SPDXPackage, scope Scope) SPDXRelationship {
+	switch scope {
+	case ScopeTest:
+		return SPDXRelationship{SPDXElementID: pkg.SPDXID, RelationshipType: "TEST_DEPENDENCY_OF", RelatedSPDXElement: root.SPDXID}
+	case ScopeTool:
+		return SPDXRelationship{SPDXElementID: pkg.SPDXID, RelationshipType: "DEV_TOOL_OF", RelatedSPDXElement: root.SPDXID}
+	default:
+		return SPDXRelationship{SPDXElementID: root.SPDXID, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: pkg.SPDXID}
+	}
+}
+
+func (d SPDXDocument) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// WriteTagValue writes the document in the SPDX tag-value format. Files are
+// written before any package so that they are not taken to be part of the
+// package they would otherwise follow; the CONTAINS relationships say which
+// package they belong to.
+func (d SPDXDocument) WriteTagValue(w io.Writer) error {
+	tw := &tagValueWriter{w: w}
+
+	tw.tag("SPDXVersion", d.SPDXVersion)
+	tw.tag("DataLicense", d.DataLicense)
+	tw.tag("SPDXID", d.SPDXID)
+	tw.tag("DocumentName", d.Name)
+	tw.tag("DocumentNamespace", d.DocumentNamespace)
+	for _, creator := range d.CreationInfo.Creators {
+		tw.tag("Creator", creator)
+	}
+	tw.tag("Created", d.CreationInfo.Created)
+
+	for _, file := range d.Files {
+		tw.blank()
+		tw.tag("FileName", file.FileName)
+		tw.tag("SPDXID", file.SPDXID)
+		for _, checksum := range file.Checksums {
+			tw.tag("FileChecksum", checksum.Algorithm+": "+checksum.ChecksumValue)
+		}
+		tw.tag("LicenseConcluded", file.LicenseConcluded)
+		for _, info := range file.LicenseInfoInFiles {
+			tw.tag("LicenseInfoInFile", info)
+		}
+		tw.tag("FileCopyrightText", file.CopyrightText)
+	}
+
+	for _, pkg := range d.Packages {
+		tw.blank()
+		tw.tag("PackageName", pkg.Name)
+		tw.tag("SPDXID", pkg.SPDXID)
+		if pkg.VersionInfo != "" {
+			tw.tag("PackageVersion", pkg.VersionInfo)
+		}
+		tw.tag("PackageDownloadLocation", pkg.DownloadLocation)
+		tw.tag("FilesAnalyzed", fmt.Sprintf("%t", pkg.FilesAnalyzed))
+		if pkg.VerificationCode != nil {
+			tw.tag("PackageVerificationCode", pkg.VerificationCode.Value)
+		}
+		tw.tag("PackageLicenseConcluded", pkg.LicenseConcluded)
+		for _, info := range pkg.LicenseInfoFromFiles {
+			tw.tag("PackageLicenseInfoFromFiles", info)
+		}
+		tw.tag("PackageLicenseDeclared", pkg.LicenseDeclared)
+		if pkg.LicenseComments != "" {
+			tw.tag("PackageLicenseComments", "<text>"+pkg.LicenseComments+"</text>")
+		}
+		tw.tag("PackageCopyrightText", pkg.CopyrightText)
+		for _, ref := range pkg.ExternalRefs {
+			tw.tag("ExternalRef", strings.Join([]string{ref.ReferenceCategory, ref.ReferenceType, ref.ReferenceLocator}, " "))
+		}
+	}
+
+	for _, license := range d.ExtractedLicenses {
+		tw.blank()
+		tw.tag("LicenseID", license.LicenseID)
+		tw.tag("ExtractedText", "<text>"+license.ExtractedText+"</text>")
+		tw.tag("LicenseName", license.Name)
+	}
+
+	tw.blank()
+	for _, relationship := range d.Relationships {
+		tw.tag("Relationship", strings.Join([]string{relationship.SPDXElementID, relationship.RelationshipType, relationship.RelatedSPDXElement}, " "))
+	}
+
+	return tw.err
+}
+
+type tagValueWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *tagValueWriter) tag(tag string, value string) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = fmt.Fprintf(t.w, "%s: %s\n", tag, value)
+}
+
+func (t *tagValueWriter) blank() {
+	if t.err != nil {
+		return
+	}
+	_, t.err = fmt.Fprintln(t.w)
+}
+
+// spdxLicenseExpression rewrites a license expression using SPDX license
+// identifiers, or a LicenseRef for licenses that do not have one. Licenses
+// that we could not make sense of are NOASSERTION.
+func spdxLicenseExpression(license string) string {
+	expression, err := ParseExpression(license)
+	if err != nil {
+		return spdxNoAssertion
+	}
+
+	converted, ok := spdxExpression(expression)
+	if !ok {
+		return spdxNoAssertion
+	}
+
+	return converted.String()
+}
+
+func spdxExpression(expression Expression) (Expression, bool) {
+	if expression.IsLicense() {
+		switch expression.License {
+		case unknownLicense.License, "Error":
+			return expression, false
+		}
+
+		if identifier, ok := spdxLicenseIdentifiers[expression.License]; ok {
+			return NewLicenseExpression(identifier), true
+		}
+
+		if !spdxListed(expression.License) {
+			return NewLicenseExpression(spdxLicenseRefID(expression.License)), true
+		}
+
+		return expression, true
+	}
+
+	operands := make([]Expression, len(expression.Operands))
+	for i, operand := range expression.Operands {
+		converted, ok := spdxExpression(operand)
+		if !ok {
+			return expression, false
+		}
+		operands[i] = converted
+	}
+
+	return Expression{Operator: expression.Operator, Operands: operands}, true
+}
+
+// spdxLicenseIdentifier returns the SPDX identifier of a single license, or
+// false if it does not have one.
+func spdxLicenseIdentifier(license string) (string, bool) {
+	if identifier, ok := spdxLicenseIdentifiers[license]; ok {
+		return identifier, !strings.Contains(identifier, " ")
+	}
+
+	switch license {
+	case unknownLicense.License, "Error":
+		return "", false
+	}
+
+	if !spdxListed(license) || strings.Contains(license, " ") {
+		return "", false
+	}
+
+	return license, true
+}
+
+// spdxListed reports whether a license is on the SPDX License List, or is
+// already a LicenseRef. Licenses with an exception or an or-later + are
+// looked up without them.
+func spdxListed(license string) bool {
+	if strings.HasPrefix(license, "LicenseRef-") || strings.HasPrefix(license, "DocumentRef-") {
+		return true
+	}
+
+	license = strings.TrimSuffix(strings.SplitN(license, " WITH ", 2)[0], "+")
+	if contains(nonSPDXLicenses, license) {
+		return false
+	}
+	if _, ok := spdxLicenseIdentifiers[license]; ok {
+		return false
+	}
+
+	return contains(knownLicenses(), license)
+}
+
+func spdxLicenseInfo(expression Expression) []string {
+	var licenses []string
+	for _, license := range expression.Licenses() {
+		converted := spdxLicenseExpression(license)
+		if !contains(licenses, converted) {
+			licenses = append(licenses, converted)
+		}
+	}
+	return licenses
+}
+
+// PackageURL is the purl of a Go package at a version, if it is known.
+func PackageURL(importPath string, version string) string {
+	purl := "pkg:golang/" + importPath
+	if version != "" {
+		purl += "@" + version
+	}
+	return purl
+}
+
+func randomIdentifier() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
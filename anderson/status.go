@@ -1,7 +1,40 @@
 package anderson
 
+import "fmt"
+
 type LicenseStatus int
 
+var licenseStatusNames = map[LicenseStatus]string{
+	LicenseTypeUnknown:   "unknown",
+	LicenseTypeNoLicense: "no-license",
+	LicenseTypeBanned:    "banned",
+	LicenseTypeAllowed:   "allowed",
+	LicenseTypeMarginal:  "marginal",
+	LicenseTypeChanged:   "changed",
+}
+
+// String returns the stable name of the status that is used in reports.
+func (s LicenseStatus) String() string {
+	if name, ok := licenseStatusNames[s]; ok {
+		return name
+	}
+	return "error"
+}
+
+func (s LicenseStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *LicenseStatus) UnmarshalText(text []byte) error {
+	for status, name := range licenseStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown license status: %s", text)
+}
+
 func (s LicenseStatus) Color() string {
 	switch s {
 	case LicenseTypeUnknown:
@@ -14,6 +47,8 @@ func (s LicenseStatus) Color() string {
 		return "red"
 	case LicenseTypeMarginal:
 		return "yellow"
+	case LicenseTypeChanged:
+		return "light_magenta"
 	default:
 		return "red"
 	}
@@ -31,6 +66,8 @@ func (s LicenseStatus) Message() string {
 		return "CONTRABAND"
 	case LicenseTypeMarginal:
 		return "BORDERLINE"
+	case LicenseTypeChanged:
+		return "CHANGED"
 	default:
 		return "ERROR"
 	}
@@ -48,15 +85,40 @@ func (s LicenseStatus) FailsBuild() bool {
 		return true
 	case LicenseTypeMarginal:
 		return true
+	case LicenseTypeChanged:
+		return true
 	default:
 		return true
 	}
 }
 
+// severity orders statuses from the most to the least acceptable. It is used
+// to pick the best choice from alternative licenses and the worst of licenses
+// that all apply.
+func (s LicenseStatus) severity() int {
+	switch s {
+	case LicenseTypeAllowed:
+		return 0
+	case LicenseTypeMarginal:
+		return 1
+	case LicenseTypeChanged:
+		return 2
+	case LicenseTypeNoLicense:
+		return 3
+	case LicenseTypeUnknown:
+		return 4
+	case LicenseTypeBanned:
+		return 5
+	default:
+		return 6
+	}
+}
+
 const (
 	LicenseTypeUnknown LicenseStatus = iota
 	LicenseTypeNoLicense
 	LicenseTypeBanned
 	LicenseTypeAllowed
 	LicenseTypeMarginal
+	LicenseTypeChanged
 )
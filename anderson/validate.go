@@ -0,0 +1,330 @@
+package anderson
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/ryanuber/go-license"
+)
+
+var (
+	configKeys      = []string{"extends", "whitelist", "blacklist", "exceptions", "prefer", "overrides", "scopes", "extra_license_files"}
+	scopePolicyKeys = []string{"whitelist", "blacklist"}
+	exceptionKeys   = []string{"path", "license", "sha256", "reason", "approved_by", "expires"}
+	overrideKeys    = []string{"license", "reference"}
+
+	decoderPosition = regexp.MustCompile(` at line (\d+), column (\d+)`)
+	gnuLicense      = regexp.MustCompile(`^(A|L)?GPL-\d\.\d$`)
+)
+
+// ConfigProblem is a mistake in a config file. Problems that are not about a
+// particular place in the file have no position.
+type ConfigProblem struct {
+	Position
+	Message string
+}
+
+type byPosition []ConfigProblem
+
+func (p byPosition) Len() int      { return len(p) }
+func (p byPosition) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPosition) Less(i, j int) bool {
+	if p[i].Line != p[j].Line {
+		return p[i].Line < p[j].Line
+	}
+	return p[i].Column < p[j].Column
+}
+
+// ValidateConfig checks a config file for the mistakes that would otherwise
+// quietly stop it from doing what was meant: keys and licenses that anderson
+// does not know, licenses that are both whitelisted and blacklisted, and
+// exceptions that have expired or that match none of the dependencies.
+func ValidateConfig(path string, dependencies []Dependency, now time.Time) ([]ConfigProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	decodeErr := candiedyaml.NewDecoder(bytes.NewReader(data)).Decode(&config)
+	if decodeErr != nil {
+		if match := decoderPosition.FindStringSubmatch(decodeErr.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			column, _ := strconv.Atoi(match[2])
+			message := strings.Replace(decodeErr.Error(), match[0], "", 1)
+			return []ConfigProblem{{Position{line, column}, message}}, nil
+		}
+	}
+
+	problems := newConfigValidator(dependencies, now).validate(parseYAMLOutline(data))
+
+	if decodeErr != nil {
+		if len(problems) == 0 {
+			problems = append(problems, ConfigProblem{Message: decodeErr.Error()})
+		}
+	} else if _, err := LoadConfig(path); err != nil {
+		problems = append(problems, ConfigProblem{Message: err.Error()})
+	}
+
+	sort.Stable(byPosition(problems))
+
+	return problems, nil
+}
+
+type configValidator struct {
+	licenses     []string
+	dependencies []Dependency
+	now          time.Time
+	problems     []ConfigProblem
+}
+
+func newConfigValidator(dependencies []Dependency, now time.Time) *configValidator {
+	return &configValidator{
+		licenses:     knownLicenses(),
+		dependencies: dependencies,
+		now:          now,
+	}
+}
+
+// knownLicenses are the license names that a config can use: the names
+// that anderson reports licenses by, along with their SPDX identifiers and
+// the -only and -or-later forms of the GNU licenses.
+func knownLicenses() []string {
+	names := append(LicenseNames(), license.KnownLicenses...)
+
+	for _, identifier := range spdxLicenseIdentifiers {
+		names = append(names, strings.SplitN(identifier, " WITH ", 2)[0])
+	}
+
+	for _, name := range names {
+		if gnuLicense.MatchString(name) {
+			names = append(names, name+"-only", name+"-or-later")
+		}
+	}
+
+	var licenses []string
+	for _, name := range names {
+		if !contains(licenses, name) {
+			licenses = append(licenses, name)
+		}
+	}
+	sort.Strings(licenses)
+	return licenses
+}
+
+func (v *configValidator) validate(root *yamlNode) []ConfigProblem {
+	if len(root.Children) == 0 && root.Value == "" {
+		return nil
+	}
+
+	if !root.Mapping {
+		v.report(root.Position, "the config should be a mapping of settings")
+		return v.problems
+	}
+
+	v.checkKeys(root, configKeys, "")
+
+	v.checkPolicy(root, "")
+	v.checkLicenses(root.child("prefer"), "prefer")
+
+	if scopes := root.child("scopes"); scopes != nil {
+		for _, scope := range scopes.Children {
+			if _, ok := scopeWidths[Scope(scope.Key)]; !ok {
+				v.report(scope.KeyAt, "unknown scope %q, the scopes are runtime, test and tool", scope.Key)
+				continue
+			}
+
+			v.checkKeys(scope, scopePolicyKeys, "scopes."+scope.Key+".")
+			v.checkPolicy(scope, "scopes."+scope.Key+".")
+		}
+	}
+
+	for _, item := range root.child("exceptions").items() {
+		v.checkException(item)
+	}
+
+	if overrides := root.child("overrides"); overrides != nil {
+		for _, override := range overrides.Children {
+			v.checkOverride(override)
+		}
+	}
+
+	for _, entry := range root.child("extra_license_files").items() {
+		v.checkLicenseFilePattern(entry)
+	}
+
+	return v.problems
+}
+
+func (v *configValidator) checkKeys(node *yamlNode, keys []string, prefix string) {
+	for _, child := range node.Children {
+		if !contains(keys, child.Key) {
+			v.report(child.KeyAt, "unknown key %q", prefix+child.Key)
+		}
+	}
+}
+
+// checkPolicy checks a whitelist and blacklist that are used together.
+func (v *configValidator) checkPolicy(node *yamlNode, prefix string) {
+	whitelist := node.child("whitelist")
+	blacklist := node.child("blacklist")
+
+	v.checkLicenses(whitelist, prefix+"whitelist")
+	v.checkLicenses(blacklist, prefix+"blacklist")
+
+	var allowed []string
+	for _, entry := range whitelist.items() {
+		allowed = append(allowed, entry.Value)
+	}
+
+	for _, entry := range blacklist.items() {
+		if contains(allowed, entry.Value) {
+			v.report(entry.Position, "%q is in both %swhitelist and %sblacklist", entry.Value, prefix, prefix)
+		}
+	}
+}
+
+func (v *configValidator) checkLicenses(list *yamlNode, name string) {
+	for _, entry := range list.items() {
+		v.checkLicense(entry.Position, entry.Value, name)
+	}
+}
+
+func (v *configValidator) checkLicense(at Position, license string, name string) {
+	base := strings.SplitN(license, " WITH ", 2)[0]
+	base = strings.TrimSuffix(base, "+")
+	if contains(v.licenses, base) {
+		return
+	}
+
+	if suggestion := v.suggestLicense(base); suggestion != "" {
+		v.report(at, "unknown license %q in %s, did you mean %q?", license, name, suggestion)
+		return
+	}
+
+	v.report(at, "unknown license %q in %s", license, name)
+}
+
+// suggestLicense finds the license that a misspelt one was most likely meant
+// to be: the shortest that it is a prefix of, ignoring case.
+func (v *configValidator) suggestLicense(license string) string {
+	var suggestion string
+	for _, known := range v.licenses {
+		if !strings.HasPrefix(strings.ToLower(known), strings.ToLower(license)) {
+			continue
+		}
+		if suggestion == "" || len(known) < len(suggestion) {
+			suggestion = known
+		}
+	}
+	return suggestion
+}
+
+func (v *configValidator) checkException(node *yamlNode) {
+	var exception Exception
+	var expires *yamlNode
+
+	switch {
+	case node.Mapping:
+		v.checkKeys(node, exceptionKeys, "exceptions.")
+
+		exception.Path = node.child("path").value()
+		exception.License = node.child("license").value()
+		expires = node.child("expires")
+		exception.Expires = expires.value()
+
+		if license := node.child("license"); license != nil {
+			v.checkExpression(license, "exceptions.license")
+		}
+	case node.Sequence:
+		v.report(node.Position, "exceptions should be import paths or mappings")
+		return
+	default:
+		exception.Path = node.Value
+	}
+
+	if exception.Path == "" {
+		v.report(node.Position, "exception has no path")
+		return
+	}
+
+	if _, err := exception.expiryDate(); err != nil {
+		v.report(expires.Position, "%s", err)
+		return
+	}
+
+	if exception.Expired(v.now) {
+		v.report(expires.Position, "exception for %s expired on %s", exception.Path, exception.Expires)
+		return
+	}
+
+	if v.dependencies != nil && !v.matchesDependency(exception) {
+		v.report(node.Position, "exception for %s does not match any dependency", exception.Path)
+	}
+}
+
+func (v *configValidator) checkOverride(node *yamlNode) {
+	v.checkKeys(node, overrideKeys, "overrides."+node.Key+".")
+
+	license := node.child("license")
+	if license == nil || license.Value == "" {
+		v.report(node.KeyAt, "override for %s has no license", node.Key)
+		return
+	}
+
+	v.checkExpression(license, "overrides.license")
+}
+
+// checkLicenseFilePattern makes sure that an extra_license_files entry is a
+// glob that stays within the directory of the dependency it is matched in.
+func (v *configValidator) checkLicenseFilePattern(node *yamlNode) {
+	if _, err := path.Match(node.Value, ""); err != nil {
+		v.report(node.Position, "bad pattern %q in extra_license_files", node.Value)
+		return
+	}
+
+	if path.IsAbs(node.Value) || node.Value == ".." || strings.HasPrefix(node.Value, "../") {
+		v.report(node.Position, "extra_license_files entry %q should be relative to the directory of a dependency", node.Value)
+	}
+}
+
+func (v *configValidator) checkExpression(node *yamlNode, name string) {
+	expression, err := ParseExpression(node.Value)
+	if err != nil {
+		v.report(node.Position, "%s", err)
+		return
+	}
+
+	for _, license := range expression.Licenses() {
+		v.checkLicense(node.Position, license, name)
+	}
+}
+
+func (v *configValidator) matchesDependency(exception Exception) bool {
+	for _, dependency := range v.dependencies {
+		license, err := ParseExpression(dependency.License)
+		if err != nil {
+			license = NewLicenseExpression(dependency.License)
+		}
+
+		if exception.Matches(dependency.ImportPath, license) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *configValidator) report(at Position, format string, args ...interface{}) {
+	v.problems = append(v.problems, ConfigProblem{
+		Position: at,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
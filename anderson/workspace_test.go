@@ -0,0 +1,188 @@
+package anderson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const (
+	benchmarkPackages          = 1000
+	benchmarkTransitiveImports = 5
+)
+
+// BenchmarkListDependencies compares loading a generated workspace in one go
+// list with what anderson used to run: three go lists for the project's
+// packages, their test imports and then every dependency again.
+func BenchmarkListDependencies(b *testing.B) {
+	gopath := b.TempDir()
+	project := generateWorkspace(b, gopath, benchmarkPackages)
+
+	b.Setenv("GOPATH", gopath)
+	b.Setenv("GO111MODULE", "off")
+	b.Setenv("GOFLAGS", "")
+	b.Chdir(project)
+
+	b.Run("single-pass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			workspace, err := LoadWorkspace()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			dependencies, err := workspace.ListDependencies()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(dependencies) != 2*benchmarkPackages {
+				b.Fatalf("found %d dependencies, expected %d", len(dependencies), 2*benchmarkPackages)
+			}
+
+			workspace.ImportGraph()
+		}
+	})
+
+	b.Run("three-pass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dependencies, err := threePassDependencies()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(dependencies) != 2*benchmarkPackages {
+				b.Fatalf("found %d dependencies, expected %d", len(dependencies), 2*benchmarkPackages)
+			}
+		}
+	})
+}
+
+// generateWorkspace writes a project with count packages to a GOPATH. Every
+// package imports a dependency of its own and its tests import another. The
+// dependencies import the next few after them and parts of the standard
+// library, like real ones do, so most of the workspace is only reached
+// transitively.
+func generateWorkspace(b *testing.B, gopath string, count int) string {
+	src := filepath.Join(gopath, "src", "example.com")
+	project := filepath.Join(src, "project")
+
+	for i := 0; i < count; i++ {
+		for _, kind := range []string{"dep", "testdep"} {
+			name := fmt.Sprintf("%s%d", kind, i)
+			imports := "\t_ \"encoding/json\"\n\t_ \"net/http\"\n"
+			for next := i + 1; next < count && next <= i+benchmarkTransitiveImports; next++ {
+				imports += fmt.Sprintf("\t_ \"example.com/deps/%s%d\"\n", kind, next)
+			}
+
+			writeBenchmarkFile(b, filepath.Join(src, "deps", name, name+".go"),
+				fmt.Sprintf("package %s\n\nimport (\n%s)\n", name, imports))
+			writeBenchmarkFile(b, filepath.Join(src, "deps", name, "util.go"),
+				fmt.Sprintf("package %s\n\nimport _ \"strings\"\n", name))
+		}
+
+		pkg := filepath.Join(project, fmt.Sprintf("pkg%d", i))
+		writeBenchmarkFile(b, filepath.Join(pkg, "pkg.go"),
+			fmt.Sprintf("package pkg%d\n\nimport _ \"example.com/deps/dep%d\"\n", i, i))
+		writeBenchmarkFile(b, filepath.Join(pkg, "pkg_test.go"),
+			fmt.Sprintf("package pkg%d\n\nimport _ \"example.com/deps/testdep%d\"\n", i, i))
+	}
+
+	return project
+}
+
+func writeBenchmarkFile(b *testing.B, path string, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// threePassDependencies lists dependencies the way anderson did before it
+// loaded the workspace in a single pass.
+func threePassDependencies() ([]string, error) {
+	packages, err := listPackages("./...")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths, testImports []string
+	for _, pkg := range packages {
+		paths = append(paths, pkg.Deps...)
+		testImports = append(testImports, pkg.TestImports...)
+		testImports = append(testImports, pkg.XTestImports...)
+	}
+
+	testPackages, err := listPackages(testImports...)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range testPackages {
+		if !pkg.Standard {
+			paths = append(paths, pkg.ImportPath)
+			paths = append(paths, pkg.Deps...)
+		}
+	}
+
+	// anderson passed every path as often as it was imported, which is too
+	// many arguments for a workspace like this one, so they are deduplicated
+	// here to have something to compare against.
+	sort.Strings(paths)
+	var unique []string
+	for _, path := range paths {
+		if len(unique) == 0 || unique[len(unique)-1] != path {
+			unique = append(unique, path)
+		}
+	}
+
+	allPackages, err := listPackages(unique...)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []string
+	for _, pkg := range allPackages {
+		if !pkg.Standard && !containsPathPrefix(dependencies, pkg.ImportPath) {
+			dependencies = append(dependencies, pkg.ImportPath)
+		}
+	}
+
+	return dependencies, nil
+}
+
+type listedPackage struct {
+	ImportPath   string
+	Deps         []string
+	Standard     bool
+	TestImports  []string
+	XTestImports []string
+}
+
+// listPackages runs go list for every field of the packages, the way that
+// anderson used to.
+func listPackages(name ...string) ([]listedPackage, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+
+	output, err := exec.Command("go", append([]string{"list", "-e", "-json"}, name...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []listedPackage
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
@@ -0,0 +1,112 @@
+package anderson
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// Position is a line and column in a file, both counting from 1.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// yamlNode is a value in a YAML document together with where it starts. The
+// decoder does not say where the values that it returns came from, so the
+// config files are also read into these from the parser's events to be able
+// to point at mistakes in them.
+type yamlNode struct {
+	Position
+	Key      string
+	KeyAt    Position
+	Value    string
+	Mapping  bool
+	Sequence bool
+	Children []*yamlNode
+}
+
+// parseYAMLOutline reads the structure of the first document in data. A
+// document that can't be parsed has no structure.
+func parseYAMLOutline(data []byte) *yamlNode {
+	events, err := candiedyaml.Events(bytes.NewReader(data))
+	if err != nil || len(events) == 0 {
+		return &yamlNode{Position: Position{Line: 1, Column: 1}}
+	}
+
+	node, _ := yamlOutlineNode(events, 0)
+	return node
+}
+
+// yamlOutlineNode reads the node whose events start at i and returns it
+// along with the index of the event after it.
+func yamlOutlineNode(events []candiedyaml.Event, i int) (*yamlNode, int) {
+	event := events[i]
+	node := &yamlNode{Position: Position{Line: event.Line, Column: event.Column}}
+
+	switch event.Type {
+	case candiedyaml.SequenceStartEvent:
+		node.Sequence = true
+		for i++; i < len(events) && events[i].Type != candiedyaml.SequenceEndEvent; {
+			var item *yamlNode
+			item, i = yamlOutlineNode(events, i)
+			node.Children = append(node.Children, item)
+		}
+	case candiedyaml.MappingStartEvent:
+		node.Mapping = true
+		for i++; i+1 < len(events) && events[i].Type != candiedyaml.MappingEndEvent; {
+			key := events[i]
+
+			var value *yamlNode
+			value, i = yamlOutlineNode(events, i+1)
+			value.Key = key.Value
+			value.KeyAt = Position{Line: key.Line, Column: key.Column}
+			node.Children = append(node.Children, value)
+		}
+	default:
+		node.Value = event.Value
+	}
+
+	return node, i + 1
+}
+
+// child returns the value of a key in a mapping.
+func (n *yamlNode) child(key string) *yamlNode {
+	if n == nil {
+		return nil
+	}
+
+	for _, child := range n.Children {
+		if child.Key == key {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// value returns the value of a scalar, or nothing if there is no node.
+func (n *yamlNode) value() string {
+	if n == nil {
+		return ""
+	}
+	return n.Value
+}
+
+// items returns the values of a sequence. A single value is treated as a
+// sequence of one, the way the config lists are read.
+func (n *yamlNode) items() []*yamlNode {
+	switch {
+	case n == nil || n.Mapping:
+		return nil
+	case n.Sequence:
+		return n.Children
+	default:
+		return []*yamlNode{n}
+	}
+}
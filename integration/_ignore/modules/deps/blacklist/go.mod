@@ -0,0 +1,3 @@
+module github.com/xoebus/blacklist
+
+go 1.16
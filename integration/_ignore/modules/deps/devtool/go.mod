@@ -0,0 +1,3 @@
+module github.com/xoebus/devtool
+
+go 1.16
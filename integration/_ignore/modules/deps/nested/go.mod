@@ -0,0 +1,5 @@
+module github.com/xoebus/nested
+
+go 1.16
+
+require github.com/xoebus/whitelist v1.0.0
@@ -0,0 +1,3 @@
+package whitelist
+
+import _ "github.com/xoebus/whitelist"
@@ -0,0 +1,3 @@
+module github.com/xoebus/no-license
+
+go 1.16
@@ -0,0 +1,3 @@
+module github.com/xoebus/test_only
+
+go 1.16
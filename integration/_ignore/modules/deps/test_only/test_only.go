@@ -0,0 +1,3 @@
+package test_only
+
+const WHAT = "do"
@@ -0,0 +1,3 @@
+module github.com/xoebus/whitelist
+
+go 1.16
@@ -0,0 +1,13 @@
+module github.com/xoebus/modoverrides
+
+go 1.16
+
+require (
+	github.com/xoebus/no-license v1.0.0
+	github.com/xoebus/whitelist v1.0.0
+)
+
+replace (
+	github.com/xoebus/no-license => ../deps/no-license
+	github.com/xoebus/whitelist => ../deps/whitelist
+)
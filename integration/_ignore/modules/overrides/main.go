@@ -0,0 +1,6 @@
+package main
+
+import (
+	_ "github.com/xoebus/no-license"
+	_ "github.com/xoebus/whitelist"
+)
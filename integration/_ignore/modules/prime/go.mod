@@ -0,0 +1,23 @@
+module github.com/xoebus/modprime
+
+go 1.24
+
+require (
+	github.com/xoebus/blacklist v1.0.0
+	github.com/xoebus/devtool v1.0.0
+	github.com/xoebus/nested v1.0.0
+	github.com/xoebus/no-license v1.0.0
+	github.com/xoebus/test_only v1.0.0
+	github.com/xoebus/whitelist v1.0.0
+)
+
+replace (
+	github.com/xoebus/blacklist => ../deps/blacklist
+	github.com/xoebus/devtool => ../deps/devtool
+	github.com/xoebus/nested => ../deps/nested
+	github.com/xoebus/no-license => ../deps/no-license
+	github.com/xoebus/test_only => ../deps/test_only
+	github.com/xoebus/whitelist => ../deps/whitelist
+)
+
+tool github.com/xoebus/devtool
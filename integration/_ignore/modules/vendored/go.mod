@@ -0,0 +1,8 @@
+module github.com/xoebus/modvendored
+
+go 1.16
+
+require (
+	github.com/xoebus/no-license v1.0.0
+	github.com/xoebus/whitelist v1.0.0
+)
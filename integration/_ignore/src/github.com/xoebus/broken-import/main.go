@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/does-not-exist"
+	_ "github.com/xoebus/whitelist"
+)
+
+func main() {}
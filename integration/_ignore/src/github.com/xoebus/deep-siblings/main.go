@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/split/gpl"
+	_ "github.com/xoebus/split/plain"
+)
+
+func main() {}
@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/mixed"
+	_ "github.com/xoebus/mixed/spdx"
+)
+
+func main() {}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	_ "github.com/xoebus/dual-greylist"
+	_ "github.com/xoebus/full-text"
+	_ "github.com/xoebus/greylist-unknown"
+	_ "github.com/xoebus/multi-license"
+	_ "github.com/xoebus/no-license"
+)
@@ -0,0 +1,7 @@
+package main
+
+import (
+	_ "github.com/xoebus/blacklist"
+	_ "github.com/xoebus/test_only"
+	_ "github.com/xoebus/whitelist"
+)
@@ -0,0 +1,3 @@
+package main_test
+
+import _ "github.com/xoebus/multi-license"
@@ -0,0 +1,4 @@
+package headerbsd
+
+// No header here.
+const Other = "other"
@@ -0,0 +1,7 @@
+// Copyright 2021 Example Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package headerspdx only states its license in its source files.
+package headerspdx
+
+const What = "spdx"
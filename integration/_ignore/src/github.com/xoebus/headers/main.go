@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/header-bsd"
+	_ "github.com/xoebus/header-spdx"
+)
+
+func main() {}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	_ "github.com/xoebus/no-license"
+	_ "github.com/xoebus/tcl-license"
+	_ "github.com/xoebus/whitelist"
+)
+
+func main() {}
@@ -0,0 +1,3 @@
+package mixed
+
+import _ "github.com/xoebus/mixed/third_party/gpl"
@@ -0,0 +1,3 @@
+package gpl
+
+const What = "copied from somewhere else"
@@ -0,0 +1,10 @@
+package main
+
+import (
+	_ "github.com/xoebus/extra-license"
+	_ "github.com/xoebus/licence-names"
+	_ "github.com/xoebus/mit-license"
+	_ "github.com/xoebus/notice-license"
+)
+
+func main() {}
@@ -2,8 +2,12 @@ package main
 
 import (
 	_ "github.com/xoebus/blacklist"
+	_ "github.com/xoebus/dual-greylist"
+	_ "github.com/xoebus/dual-license"
+	_ "github.com/xoebus/full-text"
 	_ "github.com/xoebus/greylist-approve"
 	_ "github.com/xoebus/greylist-unknown"
+	_ "github.com/xoebus/multi-license"
 	_ "github.com/xoebus/nested/subdir"
 	_ "github.com/xoebus/no-license"
 	_ "github.com/xoebus/prime/subdir"
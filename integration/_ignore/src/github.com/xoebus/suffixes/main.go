@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/suffixed-extra"
+	_ "github.com/xoebus/suffixed-license"
+)
+
+func main() {}
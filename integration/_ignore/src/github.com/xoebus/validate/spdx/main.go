@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/xoebus/blacklist"
+	_ "github.com/xoebus/greylist-unknown"
+	_ "github.com/xoebus/no-license"
+	_ "github.com/xoebus/whitelist"
+)
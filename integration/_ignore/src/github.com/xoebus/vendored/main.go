@@ -0,0 +1,7 @@
+package main
+
+import (
+	_ "github.com/xoebus/no-license"
+	_ "github.com/xoebus/vendorlib"
+	_ "github.com/xoebus/whitelist"
+)
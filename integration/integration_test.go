@@ -1,11 +1,19 @@
 package integration_test
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -19,21 +27,64 @@ import (
 
 func TestIntegration(t *testing.T) {
 	RegisterFailHandler(Fail)
+	// Every run shells out to go list a few times, which can take more than
+	// the default second on a busy machine.
+	SetDefaultEventuallyTimeout(10 * time.Second)
 	RunSpecs(t, "Integration Suite")
 }
 
 var andersonPath string
 
+// cacheDir keeps the suite's runs from reading or filling the cache of
+// whoever runs it.
+var cacheDir string
+
 var _ = BeforeSuite(func() {
 	var err error
 	andersonPath, err = gexec.Build("github.com/contraband/anderson")
 	Ω(err).ShouldNot(HaveOccurred())
+
+	cacheDir, err = ioutil.TempDir("", "anderson-cache")
+	Ω(err).ShouldNot(HaveOccurred())
 })
 
 var _ = AfterSuite(func() {
 	gexec.CleanupBuildArtifacts()
+	os.RemoveAll(cacheDir)
 })
 
+type cycloneDXComponent struct {
+	Name     string `json:"name"`
+	BOMRef   string `json:"bom-ref"`
+	Scope    string `json:"scope"`
+	PURL     string `json:"purl"`
+	Licenses []struct {
+		License struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Evidence struct {
+		Occurrences []struct {
+			Location string `json:"location"`
+		} `json:"occurrences"`
+	} `json:"evidence"`
+}
+
+type cycloneDXBOM struct {
+	BOMFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Metadata    struct {
+		Component cycloneDXComponent `json:"component"`
+	} `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+	Dependencies []struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	} `json:"dependencies"`
+}
+
 var _ = Describe("Anderson", func() {
 	var andersonCommand *exec.Cmd
 
@@ -44,7 +95,10 @@ var _ = Describe("Anderson", func() {
 		gopath, err := filepath.Abs(filepath.Join("_ignore"))
 		Ω(err).ShouldNot(HaveOccurred())
 		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("PATH=%s", os.Getenv("PATH")))
+		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("HOME=%s", os.Getenv("HOME")))
 		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("GOPATH=%s", gopath))
+		andersonCommand.Env = append(andersonCommand.Env, "GO111MODULE=off")
+		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("ANDERSON_CACHE_DIR=%s", cacheDir))
 	})
 
 	runAnderson := func() *gexec.Session {
@@ -79,6 +133,34 @@ var _ = Describe("Anderson", func() {
 		Eventually(session).Should(Exit(1))
 	})
 
+	It("recognizes licenses by comparing their full text with the SPDX templates", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`github.com/xoebus/full-text.*\(ISC\).*BORDERLINE`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("elects an allowed license from dual licensed projects", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`github.com/xoebus/dual-license.*\(Apache-2.0 OR MIT, elected MIT\).*CHECKS OUT`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("elects the preferred license when the choices are equally good", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`github.com/xoebus/dual-greylist.*\(Apache-2.0 OR ISC, elected ISC\).*BORDERLINE`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("requires every license of projects with several licenses to be allowed", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`github.com/xoebus/multi-license.*\(GPL-2.0 AND MIT\).*CONTRABAND`))
+		Eventually(session).Should(Exit(1))
+	})
+
 	It("shows projects with no license as 'NO LICENSE'", func() {
 		session := runAnderson()
 
@@ -89,7 +171,7 @@ var _ = Describe("Anderson", func() {
 	It("shows projects that are only used in tests", func() {
 		session := runAnderson()
 
-		Eventually(session).Should(Say("github.com/xoebus/test_only.*NO LICENSE"))
+		Eventually(session).Should(Say(`github.com/xoebus/test_only \[test\].*NO LICENSE`))
 		Eventually(session).Should(Exit(1))
 	})
 
@@ -107,6 +189,1358 @@ var _ = Describe("Anderson", func() {
 		Eventually(session).ShouldNot(Say(`github.com/xoebus/prime/subdir`)) // does not show subdir
 	})
 
+	It("groups dependencies by status with the ones that fail the build first", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`CONTRABAND \(2\)`))
+		Eventually(session).Should(Say("github.com/xoebus/blacklist.*CONTRABAND"))
+		Eventually(session).Should(Say("github.com/xoebus/multi-license.*CONTRABAND"))
+		Eventually(session).Should(Say(`NO LICENSE \(2\)`))
+		Eventually(session).Should(Say(`BORDERLINE \(3\)`))
+		Eventually(session).Should(Say("github.com/xoebus/dual-greylist.*BORDERLINE"))
+		Eventually(session).Should(Say("github.com/xoebus/full-text.*BORDERLINE"))
+		Eventually(session).Should(Say(`CHECKS OUT \(4\)`))
+		Eventually(session).Should(Say("github.com/xoebus/whitelist.*CHECKS OUT"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("sums up the statuses and licenses and lists the dependencies that failed the build", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("Checked 11 dependencies"))
+		Eventually(session).Should(Say(`2 CONTRABAND`))
+		Eventually(session).Should(Say(`4 CHECKS OUT`))
+		Eventually(session).Should(Say(`2 MIT`))
+		Eventually(session).Should(Say(`1 GPL-2.0 AND MIT`))
+		Eventually(session).Should(Say("These dependencies failed the build:"))
+		Eventually(session).Should(Say(`github.com/xoebus/blacklist \(GPL-2.0\) .*CONTRABAND`))
+		Eventually(session).Should(Say(`github.com/xoebus/test_only \[test\] \(Unknown\) .*NO LICENSE`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("prints the same output every time", func() {
+		first := runAnderson()
+		Eventually(first).Should(Exit(1))
+
+		rerun := exec.Command(andersonPath)
+		rerun.Dir = andersonCommand.Dir
+		rerun.Env = andersonCommand.Env
+		andersonCommand = rerun
+		second := runAnderson()
+		Eventually(second).Should(Exit(1))
+
+		Ω(second.Out.Contents()).Should(Equal(first.Out.Contents()))
+	})
+
+	Context("when asked for a JSON report", func() {
+		type dependency struct {
+			ImportPath  string `json:"import_path"`
+			LicensePath string `json:"license_path"`
+			Scope       string `json:"scope"`
+			License     string `json:"license"`
+			Status      string `json:"status"`
+			FailsBuild  bool   `json:"fails_build"`
+			Rule        *struct {
+				List  string `json:"list"`
+				Entry string `json:"entry"`
+			} `json:"rule"`
+		}
+
+		var report struct {
+			Dependencies []dependency   `json:"dependencies"`
+			Totals       map[string]int `json:"totals"`
+			Total        int            `json:"total"`
+			Passed       bool           `json:"passed"`
+		}
+
+		findDependency := func(importPath string) dependency {
+			for _, dep := range report.Dependencies {
+				if dep.ImportPath == importPath {
+					return dep
+				}
+			}
+			Fail("no dependency for " + importPath)
+			return dependency{}
+		}
+
+		BeforeEach(func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+		})
+
+		It("writes a single JSON document with the verdict for every dependency", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(report.Passed).Should(BeFalse())
+			Ω(report.Total).Should(Equal(len(report.Dependencies)))
+			Ω(report.Totals).Should(HaveKeyWithValue("banned", 2))
+
+			blacklisted := findDependency("github.com/xoebus/blacklist")
+			Ω(blacklisted.License).Should(Equal("GPL-2.0"))
+			Ω(blacklisted.Status).Should(Equal("banned"))
+			Ω(blacklisted.FailsBuild).Should(BeTrue())
+			Ω(blacklisted.Rule.List).Should(Equal("blacklist"))
+			Ω(blacklisted.Rule.Entry).Should(Equal("GPL-2.0"))
+
+			nested := findDependency("github.com/xoebus/nested/subdir")
+			Ω(nested.LicensePath).Should(Equal("github.com/xoebus/nested"))
+			Ω(nested.Status).Should(Equal("allowed"))
+			Ω(nested.FailsBuild).Should(BeFalse())
+
+			approved := findDependency("github.com/xoebus/greylist-approve")
+			Ω(approved.Rule.List).Should(Equal("exceptions"))
+
+			unknown := findDependency("github.com/xoebus/greylist-unknown")
+			Ω(unknown.Status).Should(Equal("marginal"))
+			Ω(unknown.Rule).Should(BeNil())
+		})
+
+		It("records whether each dependency is used at runtime or only in tests", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(findDependency("github.com/xoebus/whitelist").Scope).Should(Equal("runtime"))
+			Ω(findDependency("github.com/xoebus/nested/subdir").Scope).Should(Equal("runtime"))
+			Ω(findDependency("github.com/xoebus/test_only").Scope).Should(Equal("test"))
+		})
+
+		It("writes the same report whatever the number of jobs", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--jobs", "1")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+			serial := session.Out.Contents()
+
+			andersonCommand = exec.Command(andersonPath, "--format", "json", "--jobs", "8")
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "prime")
+			andersonCommand.Env = session.Command.Env
+			session = runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			Ω(session.Out.Contents()).Should(Equal(serial))
+		})
+	})
+
+	Context("when asked for an SPDX document", func() {
+		type spdxDocument struct {
+			SPDXVersion string `json:"spdxVersion"`
+			Packages    []struct {
+				Name             string `json:"name"`
+				SPDXID           string `json:"SPDXID"`
+				VersionInfo      string `json:"versionInfo"`
+				LicenseConcluded string `json:"licenseConcluded"`
+				LicenseDeclared  string `json:"licenseDeclared"`
+			} `json:"packages"`
+			Files []struct {
+				FileName  string `json:"fileName"`
+				Checksums []struct {
+					Algorithm string `json:"algorithm"`
+				} `json:"checksums"`
+			} `json:"files"`
+			Relationships []struct {
+				SPDXElementID      string `json:"spdxElementId"`
+				RelationshipType   string `json:"relationshipType"`
+				RelatedSPDXElement string `json:"relatedSpdxElement"`
+			} `json:"relationships"`
+		}
+
+		It("writes SPDX JSON with a package for every dependency", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var document spdxDocument
+			Ω(json.Unmarshal(session.Out.Contents(), &document)).Should(Succeed())
+			Ω(document.SPDXVersion).Should(Equal("SPDX-2.3"))
+
+			licenses := map[string]string{}
+			for _, pkg := range document.Packages {
+				licenses[pkg.Name] = pkg.LicenseConcluded + " / " + pkg.LicenseDeclared
+			}
+			Ω(licenses).Should(HaveKeyWithValue("github.com/xoebus/blacklist", "GPL-2.0 / GPL-2.0"))
+			Ω(licenses).Should(HaveKeyWithValue("github.com/xoebus/dual-license", "MIT / Apache-2.0 OR MIT"))
+			Ω(licenses).Should(HaveKeyWithValue("github.com/xoebus/no-license", "NONE / NONE"))
+
+			ids := map[string]string{}
+			for _, pkg := range document.Packages {
+				ids[pkg.SPDXID] = pkg.Name
+			}
+
+			dependencies := map[string]string{}
+			for _, relationship := range document.Relationships {
+				switch relationship.RelationshipType {
+				case "DEPENDS_ON":
+					Ω(relationship.SPDXElementID).Should(Equal("SPDXRef-RootPackage"))
+					dependencies[ids[relationship.RelatedSPDXElement]] = relationship.RelationshipType
+				case "TEST_DEPENDENCY_OF":
+					Ω(relationship.RelatedSPDXElement).Should(Equal("SPDXRef-RootPackage"))
+					dependencies[ids[relationship.SPDXElementID]] = relationship.RelationshipType
+				}
+			}
+			Ω(dependencies).Should(HaveLen(len(document.Packages) - 1))
+			Ω(dependencies).Should(HaveKeyWithValue("github.com/xoebus/blacklist", "DEPENDS_ON"))
+			Ω(dependencies).Should(HaveKeyWithValue("github.com/xoebus/test_only", "TEST_DEPENDENCY_OF"))
+
+			Ω(document.Files).ShouldNot(BeEmpty())
+			Ω(document.Files[0].Checksums[0].Algorithm).Should(Equal("SHA1"))
+		})
+
+		It("refers to licenses that are not on the SPDX License List by a LicenseRef", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "license-ref")
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			var document struct {
+				Packages []struct {
+					Name             string `json:"name"`
+					FilesAnalyzed    bool   `json:"filesAnalyzed"`
+					VerificationCode *struct {
+						Value string `json:"packageVerificationCodeValue"`
+					} `json:"packageVerificationCode"`
+					LicenseConcluded string `json:"licenseConcluded"`
+				} `json:"packages"`
+				ExtractedLicenses []struct {
+					LicenseID     string `json:"licenseId"`
+					ExtractedText string `json:"extractedText"`
+					Name          string `json:"name"`
+				} `json:"hasExtractedLicensingInfos"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &document)).Should(Succeed())
+
+			var checksums []string
+			for _, name := range []string{"LICENSE", "doc.go"} {
+				contents, err := ioutil.ReadFile(filepath.Join("_ignore", "src", "github.com", "xoebus", "tcl-license", name))
+				Ω(err).ShouldNot(HaveOccurred())
+				sum := sha1.Sum(contents)
+				checksums = append(checksums, hex.EncodeToString(sum[:]))
+			}
+			sort.Strings(checksums)
+			verificationCode := sha1.Sum([]byte(strings.Join(checksums, "")))
+
+			concluded := map[string]string{}
+			for _, pkg := range document.Packages {
+				concluded[pkg.Name] = pkg.LicenseConcluded
+				if pkg.Name == "github.com/xoebus/tcl-license" {
+					Ω(pkg.FilesAnalyzed).Should(BeTrue())
+					Ω(pkg.VerificationCode.Value).Should(Equal(hex.EncodeToString(verificationCode[:])))
+				}
+			}
+			Ω(concluded["github.com/xoebus/tcl-license"]).Should(Equal("LicenseRef-tcl-tk"))
+			Ω(concluded["github.com/xoebus/no-license"]).Should(Equal("LicenseRef-Acme-Proprietary"))
+
+			Ω(document.ExtractedLicenses).Should(HaveLen(2))
+			Ω(document.ExtractedLicenses[0].LicenseID).Should(Equal("LicenseRef-Acme-Proprietary"))
+			Ω(document.ExtractedLicenses[0].Name).Should(Equal("Acme-Proprietary"))
+			Ω(document.ExtractedLicenses[1].LicenseID).Should(Equal("LicenseRef-tcl-tk"))
+			Ω(document.ExtractedLicenses[1].Name).Should(Equal("tcl_tk"))
+			Ω(document.ExtractedLicenses[1].ExtractedText).Should(ContainSubstring("Regents of the University of\nCalifornia"))
+		})
+
+		It("writes SPDX tag-value documents", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-tag-value")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("SPDXVersion: SPDX-2.3"))
+			Eventually(session).Should(Say("PackageName: github.com/xoebus/blacklist"))
+			Eventually(session).Should(Say("PackageLicenseDeclared: GPL-2.0"))
+			Eventually(session).Should(Say("Relationship: SPDXRef-RootPackage DEPENDS_ON"))
+			Eventually(session).Should(Exit(1))
+		})
+	})
+
+	Context("when asked for a CycloneDX BOM", func() {
+		It("writes CycloneDX JSON with a component for every dependency", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "cyclonedx-json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var bom cycloneDXBOM
+			Ω(json.Unmarshal(session.Out.Contents(), &bom)).Should(Succeed())
+			Ω(bom.BOMFormat).Should(Equal("CycloneDX"))
+			Ω(bom.SpecVersion).Should(Equal("1.5"))
+			Ω(bom.Metadata.Component.BOMRef).Should(Equal("pkg:golang/github.com/xoebus/prime"))
+
+			components := map[string]cycloneDXComponent{}
+			for _, component := range bom.Components {
+				components[component.Name] = component
+			}
+
+			blacklist := components["github.com/xoebus/blacklist"]
+			Ω(blacklist.PURL).Should(Equal("pkg:golang/github.com/xoebus/blacklist"))
+			Ω(blacklist.Licenses).Should(HaveLen(1))
+			Ω(blacklist.Licenses[0].License.ID).Should(Equal("GPL-2.0"))
+			Ω(blacklist.Evidence.Occurrences[0].Location).Should(Equal("github.com/xoebus/blacklist/LICENSE"))
+
+			dual := components["github.com/xoebus/dual-license"]
+			Ω(dual.Licenses[0].Expression).Should(Equal("Apache-2.0 OR MIT"))
+			Ω(dual.Evidence.Occurrences).Should(HaveLen(2))
+
+			Ω(components["github.com/xoebus/no-license"].Licenses).Should(BeEmpty())
+
+			Ω(blacklist.Scope).Should(Equal("required"))
+			Ω(components["github.com/xoebus/test_only"].Scope).Should(Equal("excluded"))
+
+			Ω(bom.Dependencies[0].Ref).Should(Equal(bom.Metadata.Component.BOMRef))
+			Ω(bom.Dependencies[0].DependsOn).Should(HaveLen(len(bom.Components)))
+		})
+
+		It("writes CycloneDX XML", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "cyclonedx-xml")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`<bom xmlns="http://cyclonedx.org/schema/bom/1.5"`))
+			Eventually(session).Should(Say(`<component type="library" bom-ref="pkg:golang/github.com/xoebus/blacklist">`))
+			Eventually(session).Should(Say(`<id>GPL-2.0</id>`))
+			Eventually(session).Should(Say(`<location>github.com/xoebus/blacklist/LICENSE</location>`))
+			Eventually(session).Should(Say(`<dependency ref="pkg:golang/github.com/xoebus/prime">`))
+			Eventually(session).Should(Exit(1))
+		})
+	})
+
+	Context("when asked to explain the verdicts", func() {
+		It("shows every directory that was checked and the rule that decided the status", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--explain")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("github.com/xoebus/blacklist.*CONTRABAND"))
+			Eventually(session).Should(Say(`LICENSE is GPL-2.0: contains the key phrases of GPL-2.0`))
+			Eventually(session).Should(Say(`GPL-2.0 matches blacklist entry "GPL-2.0" so it is CONTRABAND`))
+			Eventually(session).Should(Say("github.com/xoebus/no-license.*NO LICENSE"))
+			Eventually(session).Should(Say(`stopped at .*_ignore because it is outside .*_ignore/src`))
+			Eventually(session).Should(Say("github.com/xoebus/dual-greylist.*BORDERLINE"))
+			Eventually(session).Should(Say(`LICENSE-ISC is ISC: matches the SPDX template for ISC with \d+. confidence`))
+			Eventually(session).Should(Say(`ISC is in neither the whitelist nor the blacklist so it is BORDERLINE`))
+			Eventually(session).Should(Say(`elected ISC from Apache-2.0 OR ISC`))
+			Eventually(session).Should(Say("github.com/xoebus/greylist-approve.*CHECKS OUT"))
+			Eventually(session).Should(Say(`github.com/xoebus/greylist-approve is in the exceptions`))
+			Eventually(session).Should(Say("github.com/xoebus/nested.*CHECKS OUT"))
+			Eventually(session).Should(Say(`no license files in .*github.com/xoebus/nested/subdir`))
+			Eventually(session).Should(Say(`found license files in .*github.com/xoebus/nested\n`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("includes the explanation in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--explain", "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath string   `json:"import_path"`
+					Trace      []string `json:"trace"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(report.Dependencies[0].Trace).Should(ContainElement("status is CONTRABAND"))
+		})
+
+		It("explains a single import path", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/unrecognized")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("github.com/xoebus/unrecognized.*UNKNOWN"))
+			Eventually(session).Should(Say(`LICENSE is Unknown: closest SPDX template is .* with only \d+. confidence, 80. is needed`))
+			Eventually(session).Should(Say("status is UNKNOWN"))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when there is a baseline", func() {
+		var baselineDir, baselinePath string
+
+		BeforeEach(func() {
+			var err error
+			baselineDir, err = ioutil.TempDir("", "anderson-baseline")
+			Ω(err).ShouldNot(HaveOccurred())
+			baselinePath = filepath.Join(baselineDir, "baseline.yml")
+
+			record := exec.Command(andersonPath, "baseline", "--file", baselinePath)
+			record.Dir = andersonCommand.Dir
+			record.Env = andersonCommand.Env
+			session, err := gexec.Start(record, GinkgoWriter, GinkgoWriter)
+			Ω(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(Exit(0))
+
+			andersonCommand.Args = append(andersonCommand.Args, "--baseline", baselinePath)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(baselineDir)
+		})
+
+		It("records every finding that fails the build", func() {
+			contents, err := ioutil.ReadFile(baselinePath)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(string(contents)).Should(ContainSubstring("- import_path: github.com/xoebus/blacklist\n  license: GPL-2.0\n  status: banned\n  sha256:\n  - "))
+			Ω(string(contents)).Should(ContainSubstring("- import_path: github.com/xoebus/no-license\n"))
+			Ω(string(contents)).ShouldNot(ContainSubstring("github.com/xoebus/whitelist"))
+		})
+
+		It("does not fail the build for findings in the baseline", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/blacklist.*CONTRABAND \(baseline\)`))
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist.*CHECKS OUT`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("fails the build for new or changed findings and lists entries that have gone away", func() {
+			contents, err := ioutil.ReadFile(baselinePath)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			changed := strings.Replace(string(contents), "import_path: github.com/xoebus/blacklist", "import_path: github.com/xoebus/gone", 1)
+			changed = strings.Replace(changed, "import_path: github.com/xoebus/full-text\n  license: ISC", "import_path: github.com/xoebus/full-text\n  license: MIT", 1)
+			Ω(ioutil.WriteFile(baselinePath, []byte(changed), 0644)).Should(Succeed())
+
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					LicensePath string `json:"license_path"`
+					FailsBuild  bool   `json:"fails_build"`
+					Baselined   bool   `json:"baselined"`
+				} `json:"dependencies"`
+				StaleBaseline []struct {
+					ImportPath string `json:"import_path"`
+				} `json:"stale_baseline"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			failing := []string{}
+			for _, dependency := range report.Dependencies {
+				if dependency.FailsBuild {
+					failing = append(failing, dependency.LicensePath)
+				}
+			}
+			Ω(failing).Should(ConsistOf("github.com/xoebus/blacklist", "github.com/xoebus/full-text"))
+
+			Ω(report.StaleBaseline).Should(HaveLen(2))
+			Ω(report.StaleBaseline[0].ImportPath).Should(Equal("github.com/xoebus/gone"))
+			Ω(report.StaleBaseline[1].ImportPath).Should(Equal("github.com/xoebus/full-text"))
+		})
+
+		It("lists stale entries in the text output", func() {
+			contents, err := ioutil.ReadFile(baselinePath)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			changed := strings.Replace(string(contents), "import_path: github.com/xoebus/blacklist", "import_path: github.com/xoebus/gone", 1)
+			Ω(ioutil.WriteFile(baselinePath, []byte(changed), 0644)).Should(Succeed())
+
+			session := runAnderson()
+
+			Eventually(session).Should(Say("These baseline entries no longer match anything and can be removed:"))
+			Eventually(session).Should(Say(`github.com/xoebus/gone \(GPL-2.0\) banned`))
+			Eventually(session).Should(Exit(1))
+		})
+	})
+
+	Context("when the exceptions have patterns, approvals and expiry dates", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "exceptions")
+		})
+
+		It("allows dependencies that match a glob or prefix with the right license", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/dual-greylist.*BORDERLINE`))
+			Eventually(session).Should(Say(`github.com/xoebus/full-text.*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/greylist-unknown.*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("needs exceptions that are pinned to license files to be reviewed again when the files change", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--explain")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`CHANGED \(1\)`))
+			Eventually(session).Should(Say(`github.com/xoebus/multi-license.*CHANGED`))
+			Eventually(session).Should(Say(`LICENSE has changed and needs to be reviewed again, its sha256 is now 9d245b2667b5ce81fcca30c4a0001288b4cef7afaa9089f960354c718c8b7840`))
+			Eventually(session).Should(Say(`github.com/xoebus/greylist-unknown.*CHECKS OUT`))
+			Eventually(session).Should(Say(`These dependencies failed the build:`))
+			Eventually(session).Should(Say(`github.com/xoebus/multi-license \(GPL-2.0 AND MIT\) .*CHANGED`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("looks for the license in the parent directories before applying an exception", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "exceptions-parent")
+			andersonCommand.Args = append(andersonCommand.Args, "--explain")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/parent-license .*\(GPL-2.0\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`no license files in .*parent-license/sub`))
+			Eventually(session).Should(Say(`found license files in .*parent-license`))
+			Eventually(session).Should(Say(`github.com/xoebus/parent-license/sub is in the exceptions`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("fails the run for expired exceptions and stops applying them", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/no-license.*NO LICENSE`))
+			Eventually(session).Should(Say("These exceptions have expired and no longer apply:"))
+			Eventually(session).Should(Say(`github.com/xoebus/no-license expired on 2020-01-31`))
+			Eventually(session).Should(Say(`approved by legal@example.com`))
+			Eventually(session).Should(Say(`reason given: Waiting for upstream to add a license`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("includes the approval in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			type exception struct {
+				Path       string   `json:"path"`
+				License    string   `json:"license"`
+				SHA256     []string `json:"sha256"`
+				Reason     string   `json:"reason"`
+				ApprovedBy string   `json:"approved_by"`
+				Expires    string   `json:"expires"`
+			}
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath string `json:"import_path"`
+					Rule       *struct {
+						List      string     `json:"list"`
+						Entry     string     `json:"entry"`
+						Exception *exception `json:"exception"`
+					} `json:"rule"`
+				} `json:"dependencies"`
+				Passed            bool        `json:"passed"`
+				ExpiredExceptions []exception `json:"expired_exceptions"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(report.Passed).Should(BeFalse())
+
+			for _, dependency := range report.Dependencies {
+				if dependency.ImportPath == "github.com/xoebus/greylist-unknown" {
+					Ω(dependency.Rule.List).Should(Equal("exceptions"))
+					Ω(dependency.Rule.Entry).Should(Equal("github.com/xoebus/greylist-*"))
+					Ω(*dependency.Rule.Exception).Should(Equal(exception{
+						Path:       "github.com/xoebus/greylist-*",
+						License:    "Apache-2.0",
+						SHA256:     []string{"c5accbbd8546e94c34aed24afe689a617627d18eed5a6c48277e48db57c23851"},
+						Reason:     "Only used by the build scripts",
+						ApprovedBy: "legal@example.com",
+						Expires:    "2999-12-31",
+					}))
+				}
+			}
+
+			Ω(report.ExpiredExceptions).Should(HaveLen(1))
+			Ω(report.ExpiredExceptions[0].Path).Should(Equal("github.com/xoebus/no-license"))
+		})
+	})
+
+	Context("when the config extends shared policies", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "extended")
+
+			policies, err := filepath.Abs(filepath.Join("_ignore", "policies"))
+			Ω(err).ShouldNot(HaveOccurred())
+			andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("ANDERSON_POLICY_DIR=%s", policies))
+		})
+
+		It("applies the inherited blacklist and exceptions and the narrowed whitelist", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/blacklist.*CONTRABAND`))
+			Eventually(session).Should(Say(`github.com/xoebus/greylist-unknown.*BORDERLINE`))
+			Eventually(session).Should(Say(`github.com/xoebus/full-text.*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/no-license.*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("prints the merged config", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--print-effective-config")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).Should(Equal(`whitelist:
+- MIT
+- ISC
+blacklist:
+- GPL-2.0
+- AGPL-3.0
+exceptions:
+- path: github.com/xoebus/no-license
+  reason: Published by our own team
+prefer:
+- ISC
+- Apache-2.0
+`))
+		})
+
+		It("refuses to loosen the inherited whitelist", func() {
+			andersonCommand.Dir = filepath.Join(andersonCommand.Dir, "loosened")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`whitelist entry "LGPL-3.0" is BORDERLINE under the policy that is extended`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("bans the repository's blacklist in the inherited scopes", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "extended-overrides")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/blacklist .*\(GPL-2.0\).*CONTRABAND`))
+			Eventually(session).Should(Say(`github.com/xoebus/multi-license \[test\] .*\(GPL-2.0 AND MIT\).*CONTRABAND`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("refuses overrides that would loosen the inherited policy", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "extended-overrides", "overridden")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`overrides entry "github.com/xoebus/test_only" can't be added to the policy that is extended, add an exception for the dependency instead`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("validates the config", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "validate")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`.anderson.yml looks good`))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when asked to validate the config", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "validate")
+			andersonCommand.Args = append(andersonCommand.Args, "validate")
+		})
+
+		It("points at the mistakes in it", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`.anderson.yml:6:13: unknown license "GPL-2" in blacklist, did you mean "GPL-2.0"\?`))
+			Eventually(session).Should(Say(`.anderson.yml:6:20: "GPL-2.0" is in both whitelist and blacklist`))
+			Eventually(session).Should(Say(`.anderson.yml:8:1: unknown key "prefered"`))
+			Eventually(session).Should(Say(`.anderson.yml:12:3: unknown scope "tests", the scopes are runtime, test and tool`))
+			Eventually(session).Should(Say(`.anderson.yml:18:3: exception for github.com/xoebus/greylist-unknwon does not match any dependency`))
+			Eventually(session).Should(Say(`.anderson.yml:21:12: exception for github.com/xoebus/no-license expired on 2020-01-31`))
+			Eventually(session).Should(Say(`.anderson.yml:23:3: unknown key "exceptions.licence"`))
+			Eventually(session).Should(Say(`.anderson.yml:26:3: bad pattern "docs/\[LEGAL" in extra_license_files`))
+			Eventually(session).Should(Say(`Found 8 problems in .anderson.yml`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("reports where the YAML is broken", func() {
+			andersonCommand.Dir = filepath.Join(andersonCommand.Dir, "broken")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`.anderson.yml:4:8: yaml: .*mapping values are not allowed in this context`))
+			Eventually(session).Should(Say(`Found 1 problem in .anderson.yml`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("knows the names licenses are reported by and their SPDX identifiers", func() {
+			andersonCommand.Dir = filepath.Join(andersonCommand.Dir, "spdx")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`.anderson.yml looks good`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("says when there is nothing wrong with it", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "prime")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`.anderson.yml looks good`))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when the config overrides the license of dependencies", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "overrides")
+		})
+
+		It("judges the asserted license like a detected one", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/nested .*\(GPL-2.0, asserted\).*CONTRABAND`))
+			Eventually(session).Should(Say(`github.com/xoebus/no-license .*\(MIT, asserted\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("marks the license as asserted in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath  string `json:"import_path"`
+					LicensePath string `json:"license_path"`
+					License     string `json:"license"`
+					Status      string `json:"status"`
+					Asserted    bool   `json:"asserted"`
+					Override    *struct {
+						Reference string `json:"reference"`
+					} `json:"override"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			asserted := map[string]bool{}
+			for _, dependency := range report.Dependencies {
+				asserted[dependency.LicensePath] = dependency.Asserted
+
+				if dependency.LicensePath == "github.com/xoebus/no-license" {
+					Ω(dependency.License).Should(Equal("MIT"))
+					Ω(dependency.Status).Should(Equal("allowed"))
+					Ω(dependency.Override.Reference).Should(Equal("https://example.com/no-license/README.md"))
+				}
+			}
+
+			Ω(asserted).Should(Equal(map[string]bool{
+				"github.com/xoebus/blacklist":  false,
+				"github.com/xoebus/nested":     true,
+				"github.com/xoebus/no-license": true,
+				"github.com/xoebus/whitelist":  false,
+			}))
+		})
+
+		It("concludes the asserted license in SPDX documents without declaring it", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-tag-value")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			Ω(string(session.Out.Contents())).Should(MatchRegexp(`PackageName: github.com/xoebus/no-license
+SPDXID: SPDXRef-Package-\d+-github.com-xoebus-no-license
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: MIT
+PackageLicenseDeclared: NOASSERTION
+PackageLicenseComments: <text>The license was asserted by an override in the anderson config. Reference: https://example.com/no-license/README.md</text>`))
+		})
+	})
+
+	Context("when a dependency's license is in a directory that other dependencies are in", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "siblings")
+		})
+
+		It("only shows the imports that lead to the dependency itself", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			output := string(session.Out.Contents())
+			Ω(output).Should(ContainSubstring("imported through github.com/xoebus/siblings (test) -> github.com/xoebus/shared/a\n"))
+			Ω(output).ShouldNot(ContainSubstring("-> github.com/xoebus/shared/c"))
+		})
+	})
+
+	Context("when an import cannot be found", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "broken-import")
+		})
+
+		It("scans the rest of the dependencies and fails with the packages that could not be loaded", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`These packages could not be loaded:`))
+			Eventually(session).Should(Say(`github.com/xoebus/does-not-exist`))
+			Eventually(session).Should(Say(`cannot find package "github.com/xoebus/does-not-exist"`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("lists the packages that could not be loaded in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Total         int  `json:"total"`
+				Passed        bool `json:"passed"`
+				PackageErrors []struct {
+					ImportPath string `json:"import_path"`
+					Err        string `json:"error"`
+				} `json:"package_errors"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			Ω(report.Total).Should(Equal(1))
+			Ω(report.Passed).Should(BeFalse())
+			Ω(report.PackageErrors).Should(HaveLen(1))
+			Ω(report.PackageErrors[0].ImportPath).Should(Equal("github.com/xoebus/does-not-exist"))
+			Ω(report.PackageErrors[0].Err).Should(ContainSubstring("cannot find package"))
+		})
+	})
+
+	Context("when dependencies only state their license in their source files", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "headers")
+		})
+
+		It("reads the license from the headers of the Go files", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/header-bsd .*\(NewBSD\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`license stated in the header of bsd.go`))
+			Eventually(session).Should(Say(`github.com/xoebus/header-spdx .*\(Apache-2.0\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`license stated in the header of spdx.go`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("explains which file the license came from", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/header-spdx")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`found license headers in the Go files in .*/header-spdx`))
+			Eventually(session).Should(Say(`spdx.go is Apache-2.0: its header has SPDX-License-Identifier: Apache-2.0`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("lists the files in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath   string `json:"import_path"`
+					LicenseFiles []struct {
+						Path   string `json:"path"`
+						Match  string `json:"match"`
+						Header bool   `json:"header"`
+					} `json:"license_files"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(report.Dependencies).Should(HaveLen(2))
+
+			bsd := report.Dependencies[0]
+			Ω(bsd.ImportPath).Should(Equal("github.com/xoebus/header-bsd"))
+			Ω(bsd.LicenseFiles).Should(HaveLen(1))
+			Ω(filepath.Base(bsd.LicenseFiles[0].Path)).Should(Equal("bsd.go"))
+			Ω(bsd.LicenseFiles[0].Header).Should(BeTrue())
+			Ω(bsd.LicenseFiles[0].Match).Should(ContainSubstring("governed by a bsd-style license"))
+		})
+
+		It("only includes the header in notices", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "notices")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).Should(ContainSubstring("Use of this source code is governed by a BSD-style"))
+			Ω(string(session.Out.Contents())).ShouldNot(ContainSubstring("const What"))
+			Ω(string(session.Out.Contents())).ShouldNot(ContainSubstring("Package headerspdx"))
+		})
+	})
+
+	Context("when a dependency has other licenses inside it", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "deep")
+		})
+
+		It("only goes by the license at its root by default", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/mixed .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("applies the strictest license found in the imported packages when asked for a deep scan", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--deep")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/mixed .*\(MIT AND Apache-2.0 AND GPL-2.0\).*CONTRABAND`))
+			Eventually(session).Should(Say(`Apache-2.0 found in .*/mixed/spdx/spdx.go`))
+			Eventually(session).Should(Say(`GPL-2.0 found in .*/mixed/third_party/gpl/LICENSE`))
+			Eventually(session).Should(Exit(1))
+
+			Ω(string(session.Out.Contents())).ShouldNot(ContainSubstring("unused"))
+		})
+
+		It("lists the nested license files in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--deep", "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					LicenseFiles []struct {
+						Path string `json:"path"`
+					} `json:"license_files"`
+					Nested []struct {
+						Path    string `json:"path"`
+						License string `json:"license"`
+						Header  bool   `json:"header"`
+					} `json:"nested_license_files"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+			Ω(report.Dependencies).Should(HaveLen(1))
+
+			dependency := report.Dependencies[0]
+			Ω(dependency.LicenseFiles).Should(HaveLen(1))
+			Ω(dependency.Nested).Should(HaveLen(2))
+			Ω(dependency.Nested[0].Path).Should(HaveSuffix(filepath.Join("mixed", "spdx", "spdx.go")))
+			Ω(dependency.Nested[0].License).Should(Equal("Apache-2.0"))
+			Ω(dependency.Nested[0].Header).Should(BeTrue())
+			Ω(dependency.Nested[1].Path).Should(HaveSuffix(filepath.Join("mixed", "third_party", "gpl", "LICENSE")))
+			Ω(dependency.Nested[1].License).Should(Equal("GPL-2.0"))
+		})
+
+		It("explains where the other licenses were found", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--deep", "explain", "github.com/xoebus/mixed")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`deep scan found GPL-2.0 in third_party/gpl/LICENSE`))
+			Eventually(session).Should(Say(`together the license is MIT AND Apache-2.0 AND GPL-2.0`))
+			Eventually(session).Should(Say(`status is CONTRABAND`))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when only one of the packages of a dependency has another license", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "deep-siblings")
+			andersonCommand.Args = append(andersonCommand.Args, "--deep")
+		})
+
+		It("applies it to the whole dependency", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/split .*\(MIT AND GPL-2.0\).*CONTRABAND`))
+			Eventually(session).Should(Say(`GPL-2.0 found in .*/split/gpl/gpl.go`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("gives the same verdict when the package is explained", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/split/gpl")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/split .*\(MIT AND GPL-2.0\).*CONTRABAND`))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when license files have other names", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "names")
+		})
+
+		It("finds them", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/extra-license .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/licence-names .*\(MIT AND Apache-2.0\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/mit-license .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/notice-license .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("takes the license of REUSE license files from their names", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/licence-names")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`LICENCE is MIT`))
+			Eventually(session).Should(Say(`LICENSES/Apache-2.0.txt is Apache-2.0: it is named Apache-2.0.txt in the REUSE LICENSES directory`))
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).ShouldNot(ContainSubstring("NOTICE is"))
+		})
+
+		It("reports every license file that was found", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath   string `json:"import_path"`
+					LicenseFiles []struct {
+						Path string `json:"path"`
+					} `json:"license_files"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			src, err := filepath.Abs(filepath.Join("_ignore", "src"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			files := map[string][]string{}
+			for _, dependency := range report.Dependencies {
+				for _, file := range dependency.LicenseFiles {
+					rel, err := filepath.Rel(filepath.Join(src, filepath.FromSlash(dependency.ImportPath)), file.Path)
+					Ω(err).ShouldNot(HaveOccurred())
+					files[dependency.ImportPath] = append(files[dependency.ImportPath], filepath.ToSlash(rel))
+				}
+			}
+
+			Ω(files).Should(HaveKeyWithValue("github.com/xoebus/extra-license", []string{"docs/LEGAL.txt"}))
+			Ω(files).Should(HaveKeyWithValue("github.com/xoebus/licence-names", []string{"LICENCE", "LICENSES/Apache-2.0.txt"}))
+			Ω(files).Should(HaveKeyWithValue("github.com/xoebus/mit-license", []string{"MIT-LICENSE"}))
+			Ω(files).Should(HaveKeyWithValue("github.com/xoebus/notice-license", []string{"NOTICE"}))
+		})
+
+		It("names the license files by their path within the dependency in SPDX documents", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-tag-value")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`FileName: ./docs/LEGAL.txt`))
+			Eventually(session).Should(Say(`FileName: ./LICENCE`))
+			Eventually(session).Should(Say(`FileName: ./LICENSES/Apache-2.0.txt`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("locates the license files by their path within the dependency in CycloneDX BOMs", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "cyclonedx-json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).Should(ContainSubstring(`"location": "github.com/xoebus/extra-license/docs/LEGAL.txt"`))
+			Ω(string(session.Out.Contents())).Should(ContainSubstring(`"location": "github.com/xoebus/licence-names/LICENSES/Apache-2.0.txt"`))
+		})
+	})
+
+	Context("when the dependencies are vendored", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "vendored")
+		})
+
+		It("scans the vendored copies, including ones vendored by other dependencies", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(Unknown\).*NO LICENSE`))
+			Eventually(session).Should(Say(`github.com/xoebus/blacklist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/no-license .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/vendorlib .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("does not look for licenses above the vendor directory", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/whitelist")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`no license files in .*/vendored/vendor\n`))
+			Eventually(session).Should(Say(`stopped at .*/vendored because it is outside .*/vendored/vendor`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("explains vendored packages in the scope the scan gives them", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "explain", "github.com/xoebus/vendored/vendor/github.com/xoebus/testlib")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/testlib \[test\] .*\(MIT\)`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("reports license files that upstream has but were not vendored", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*NO LICENSE`))
+			Eventually(session).Should(Say(`LICENSE is missing from the vendored copy but upstream has it`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("lists the license files that were not vendored in JSON reports", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath string   `json:"import_path"`
+					Unvendored []string `json:"unvendored_license_files"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			unvendored := map[string][]string{}
+			for _, dependency := range report.Dependencies {
+				unvendored[dependency.ImportPath] = dependency.Unvendored
+			}
+
+			Ω(unvendored).Should(HaveLen(5))
+			Ω(unvendored["github.com/xoebus/whitelist"]).Should(Equal([]string{"LICENSE"}))
+			Ω(unvendored["github.com/xoebus/no-license"]).Should(BeEmpty())
+			Ω(unvendored["github.com/xoebus/blacklist"]).Should(BeEmpty())
+		})
+	})
+
+	Context("when there is a cache", func() {
+		var dir string
+
+		cachePath := func() string {
+			return filepath.Join(dir, "cache.json")
+		}
+
+		// writeCache caches the whitelisted dependency's license text as the
+		// wrong license, which only a run that reads the cache would believe.
+		writeCache := func() {
+			text, err := ioutil.ReadFile(filepath.Join("_ignore", "src", "github.com", "xoebus", "whitelist", "LICENSE"))
+			Ω(err).ShouldNot(HaveOccurred())
+			sum := sha256.Sum256(text)
+
+			cache := fmt.Sprintf(`{"version": 2, "licenses": {%q: {"license": "GPL-2.0", "match": "cached"}}}`, hex.EncodeToString(sum[:]))
+			Ω(ioutil.WriteFile(cachePath(), []byte(cache), 0644)).Should(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "anderson-cache")
+			Ω(err).ShouldNot(HaveOccurred())
+			andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("ANDERSON_CACHE_DIR=%s", dir))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("remembers the licenses that were identified", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var cache struct {
+				Licenses map[string]struct {
+					License string `json:"license"`
+				} `json:"licenses"`
+			}
+			contents, err := ioutil.ReadFile(cachePath())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(json.Unmarshal(contents, &cache)).Should(Succeed())
+
+			licenses := []string{}
+			for _, cached := range cache.Licenses {
+				licenses = append(licenses, cached.License)
+			}
+			Ω(licenses).Should(ContainElement("MIT"))
+			Ω(licenses).Should(ContainElement("GPL-2.0"))
+		})
+
+		It("takes licenses it has seen before from the cache", func() {
+			writeCache()
+
+			session := runAnderson()
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(GPL-2.0\).*CONTRABAND`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("ignores the cache when asked to", func() {
+			writeCache()
+			andersonCommand.Args = append(andersonCommand.Args, "--no-cache")
+
+			session := runAnderson()
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("clears the cache when asked to", func() {
+			writeCache()
+			andersonCommand.Args = append(andersonCommand.Args, "--clear-cache")
+
+			session := runAnderson()
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+
+			contents, err := ioutil.ReadFile(cachePath())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(contents)).ShouldNot(ContainSubstring(`"cached"`))
+		})
+
+		It("starts again from an empty cache when the cache is corrupt", func() {
+			Ω(ioutil.WriteFile(cachePath(), []byte(`{"version": 2, "licenses": [`), 0644)).Should(Succeed())
+
+			session := runAnderson()
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+
+			contents, err := ioutil.ReadFile(cachePath())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(json.Valid(contents)).Should(BeTrue())
+		})
+	})
+
+	Context("when asked for a JUnit report", func() {
+		type junitReport struct {
+			Tests     int `xml:"tests,attr"`
+			Failures  int `xml:"failures,attr"`
+			TestCases []struct {
+				Name       string `xml:"name,attr"`
+				Properties []struct {
+					Name  string `xml:"name,attr"`
+					Value string `xml:"value,attr"`
+				} `xml:"properties>property"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+					Type    string `xml:"type,attr"`
+				} `xml:"failure"`
+			} `xml:"testsuite>testcase"`
+		}
+
+		var junitDir, junitPath string
+
+		BeforeEach(func() {
+			var err error
+			junitDir, err = ioutil.TempDir("", "anderson-junit")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			junitPath = filepath.Join(junitDir, "junit.xml")
+			andersonCommand.Args = append(andersonCommand.Args, "--junit", junitPath)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(junitDir)
+		})
+
+		It("writes a test case for every dependency alongside the normal output", func() {
+			session := runAnderson()
+			Eventually(session).Should(Say("github.com/xoebus/blacklist.*CONTRABAND"))
+			Eventually(session).Should(Exit(1))
+
+			contents, err := ioutil.ReadFile(junitPath)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var report junitReport
+			Ω(xml.Unmarshal(contents, &report)).Should(Succeed())
+			Ω(report.Tests).Should(Equal(len(report.TestCases)))
+
+			failures := map[string]string{}
+			for _, testCase := range report.TestCases {
+				Ω(testCase.Properties).Should(HaveLen(1))
+				Ω(testCase.Properties[0].Name).Should(Equal("scope"))
+				if testCase.Name == "github.com/xoebus/test_only" {
+					Ω(testCase.Properties[0].Value).Should(Equal("test"))
+				}
+
+				if testCase.Failure != nil {
+					failures[testCase.Name] = testCase.Failure.Message
+					Ω(testCase.Failure.Type).ShouldNot(BeEmpty())
+				}
+			}
+			Ω(report.Failures).Should(Equal(len(failures)))
+
+			Ω(failures).Should(HaveKeyWithValue("github.com/xoebus/blacklist", "GPL-2.0: CONTRABAND"))
+			Ω(failures).Should(HaveKeyWithValue("github.com/xoebus/greylist-unknown", "Apache-2.0: BORDERLINE"))
+			Ω(failures).Should(HaveKeyWithValue("github.com/xoebus/no-license", "Unknown: NO LICENSE"))
+			Ω(failures).ShouldNot(HaveKey("github.com/xoebus/whitelist"))
+		})
+	})
+
+	Context("when asked for notices", func() {
+		BeforeEach(func() {
+			andersonCommand.Args = append(andersonCommand.Args, "notices")
+		})
+
+		It("writes the license text of every dependency once", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			output := string(session.Out.Contents())
+			Ω(output).Should(HavePrefix("THIRD PARTY NOTICES"))
+			Ω(strings.Count(output, "TERMS AND CONDITIONS FOR USE, REPRODUCTION, AND DISTRIBUTION")).Should(Equal(1))
+			Ω(output).Should(ContainSubstring("Apache-2.0\n\nUsed by:\n  github.com/xoebus/dual-greylist\n  github.com/xoebus/dual-license\n"))
+			Ω(output).Should(ContainSubstring("No license text was found for:\n  github.com/xoebus/no-license\n"))
+		})
+
+		It("leaves out the dependencies that do not ship", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).ShouldNot(ContainSubstring("github.com/xoebus/test_only"))
+		})
+
+		It("includes them with their scope when asked for every scope", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--all-scopes")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			Ω(string(session.Out.Contents())).Should(ContainSubstring("No license text was found for:\n  github.com/xoebus/no-license\n  github.com/xoebus/test_only [test]\n"))
+		})
+
+		It("includes NOTICE files", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			output := string(session.Out.Contents())
+			Ω(output).Should(ContainSubstring("NOTICE\n\nUsed by:\n  github.com/xoebus/dual-license\n  github.com/xoebus/greylist-unknown\n"))
+			Ω(strings.Count(output, "Copyright 2015 The Greylist Authors")).Should(Equal(1))
+		})
+
+		It("writes the same document every time", func() {
+			first := runAnderson()
+			Eventually(first).Should(Exit(0))
+
+			rerun := exec.Command(andersonPath, andersonCommand.Args[1:]...)
+			rerun.Dir = andersonCommand.Dir
+			rerun.Env = andersonCommand.Env
+			andersonCommand = rerun
+			second := runAnderson()
+			Eventually(second).Should(Exit(0))
+
+			Ω(second.Out.Contents()).Should(Equal(first.Out.Contents()))
+		})
+
+		It("can write Markdown", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "markdown")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("# Third Party Notices"))
+			Eventually(session).Should(Say("## GPL-2.0"))
+			Eventually(session).Should(Say("- github.com/xoebus/blacklist"))
+			Eventually(session).Should(Say("```"))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when license files are named after their license", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "src", "github.com", "xoebus", "suffixes")
+		})
+
+		It("offers a choice between them but requires the plain license files too", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/suffixed-extra .*\(MIT AND Apache-2.0\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/suffixed-license .*\(Apache-2.0 OR MIT, elected Apache-2.0\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("declares the same expressions in SPDX documents", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			var document struct {
+				Packages []struct {
+					Name            string `json:"name"`
+					LicenseDeclared string `json:"licenseDeclared"`
+				} `json:"packages"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &document)).Should(Succeed())
+
+			declared := map[string]string{}
+			for _, pkg := range document.Packages {
+				declared[pkg.Name] = pkg.LicenseDeclared
+			}
+			Ω(declared).Should(HaveKeyWithValue("github.com/xoebus/suffixed-extra", "MIT AND Apache-2.0"))
+			Ω(declared).Should(HaveKeyWithValue("github.com/xoebus/suffixed-license", "Apache-2.0 OR MIT"))
+		})
+
+		It("includes every license file in the notices", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "notices")
+			session := runAnderson()
+			Eventually(session).Should(Exit(0))
+
+			output := string(session.Out.Contents())
+			Ω(output).Should(ContainSubstring("Apache-2.0\n\nUsed by:\n  github.com/xoebus/suffixed-extra\n  github.com/xoebus/suffixed-license\n"))
+			Ω(output).Should(ContainSubstring("MIT\n\nUsed by:\n  github.com/xoebus/suffixed-extra\n  github.com/xoebus/suffixed-license\n"))
+		})
+	})
+
 	It("can accept a list of packages to scan on STDIN", func() {
 		andersonCommand.Stdin = strings.NewReader("github.com/xoebus/blacklist\n")
 		session := runAnderson()
@@ -115,3 +1549,277 @@ var _ = Describe("Anderson", func() {
 		Eventually(session).ShouldNot(Say("github.com/xoebus/whitelist.*CHECKS OUT"))
 	})
 })
+
+var _ = Describe("Anderson with Go modules", func() {
+	var andersonCommand *exec.Cmd
+
+	BeforeEach(func() {
+		andersonCommand = exec.Command(andersonPath)
+		andersonCommand.Dir = filepath.Join("_ignore", "modules", "prime")
+
+		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("PATH=%s", os.Getenv("PATH")))
+		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("HOME=%s", os.Getenv("HOME")))
+		andersonCommand.Env = append(andersonCommand.Env, "GO111MODULE=on")
+		andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("ANDERSON_CACHE_DIR=%s", cacheDir))
+		andersonCommand.Env = append(andersonCommand.Env, "GOFLAGS=-mod=mod")
+		andersonCommand.Env = append(andersonCommand.Env, "GOPROXY=off")
+		andersonCommand.Env = append(andersonCommand.Env, "GOTOOLCHAIN=local")
+	})
+
+	runAnderson := func() *gexec.Session {
+		session, err := gexec.Start(
+			andersonCommand,
+			gexec.NewPrefixedWriter("\x1b[32m[o]\x1b[95m[anderson]\x1b[0m ", GinkgoWriter),
+			gexec.NewPrefixedWriter("\x1b[91m[e]\x1b[95m[anderson]\x1b[0m ", GinkgoWriter),
+		)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return session
+	}
+
+	It("shows whitelisted licenses from module dependencies as 'CHECKS OUT'", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("github.com/xoebus/whitelist.*CHECKS OUT"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("shows blacklisted licenses from module dependencies as 'CONTRABAND'", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("github.com/xoebus/blacklist.*CONTRABAND"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("does not look for licenses above the module root", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("github.com/xoebus/no-license.*NO LICENSE"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("shows modules that are only used in tests", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("github.com/xoebus/test_only.*NO LICENSE"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("finds the license at the module root for packages in subdirectories", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say(`github.com/xoebus/nested[^/].*CHECKS OUT`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("includes module versions in SPDX documents", func() {
+		andersonCommand.Args = append(andersonCommand.Args, "--format", "spdx-tag-value")
+		session := runAnderson()
+
+		Eventually(session).Should(Say("PackageName: github.com/xoebus/whitelist"))
+		Eventually(session).Should(Say("PackageVersion: v1.0.0"))
+		Eventually(session).Should(Exit(1))
+	})
+
+	It("builds the CycloneDX dependency graph from the imports between modules", func() {
+		andersonCommand.Args = append(andersonCommand.Args, "--format", "cyclonedx-json")
+		session := runAnderson()
+		Eventually(session).Should(Exit(1))
+
+		var bom cycloneDXBOM
+		Ω(json.Unmarshal(session.Out.Contents(), &bom)).Should(Succeed())
+
+		dependsOn := map[string][]string{}
+		for _, dependency := range bom.Dependencies {
+			dependsOn[dependency.Ref] = dependency.DependsOn
+		}
+
+		Ω(dependsOn).Should(HaveKeyWithValue("pkg:golang/github.com/xoebus/nested@v1.0.0", []string{"pkg:golang/github.com/xoebus/whitelist@v1.0.0"}))
+		Ω(dependsOn).Should(HaveKeyWithValue("pkg:golang/github.com/xoebus/whitelist@v1.0.0", []string{}))
+		Ω(dependsOn["pkg:golang/github.com/xoebus/modprime"]).Should(ContainElement("pkg:golang/github.com/xoebus/blacklist@v1.0.0"))
+	})
+
+	It("does not show packages from the main module", func() {
+		andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+		session := runAnderson()
+		Eventually(session).Should(Exit(1))
+
+		var report struct {
+			Dependencies []struct {
+				ImportPath  string `json:"import_path"`
+				LicensePath string `json:"license_path"`
+			} `json:"dependencies"`
+		}
+		Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+		Ω(report.Dependencies).ShouldNot(BeEmpty())
+		for _, dependency := range report.Dependencies {
+			Ω(dependency.ImportPath).ShouldNot(HavePrefix("github.com/xoebus/modprime"))
+			Ω(dependency.LicensePath).ShouldNot(HavePrefix("github.com/xoebus/modprime"))
+		}
+	})
+
+	It("shows which of the project's packages import each failing dependency", func() {
+		session := runAnderson()
+
+		Eventually(session).Should(Say("github.com/xoebus/blacklist.*CONTRABAND"))
+		Eventually(session).Should(Say(`imported through github.com/xoebus/modprime -> github.com/xoebus/blacklist\n`))
+		Eventually(session).Should(Say("github.com/xoebus/test_only.*NO LICENSE"))
+		Eventually(session).Should(Say(`imported through github.com/xoebus/modprime \(test\) -> github.com/xoebus/test_only\n`))
+		Eventually(session).Should(Exit(1))
+	})
+
+	Context("when go.mod lists tools", func() {
+		It("scans the tools as tool dependencies", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/test_only \[test\].*NO LICENSE`))
+			Eventually(session).Should(Say(`github.com/xoebus/devtool \[tool\].*CHECKS OUT`))
+			Eventually(session).Should(Exit(1))
+		})
+
+		It("applies the policy for the scope before the main lists", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "--format", "json")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			var report struct {
+				Dependencies []struct {
+					ImportPath string `json:"import_path"`
+					Scope      string `json:"scope"`
+					Status     string `json:"status"`
+					Rule       *struct {
+						List string `json:"list"`
+					} `json:"rule"`
+				} `json:"dependencies"`
+			}
+			Ω(json.Unmarshal(session.Out.Contents(), &report)).Should(Succeed())
+
+			scopes := map[string]string{}
+			for _, dependency := range report.Dependencies {
+				scopes[dependency.ImportPath] = dependency.Scope
+
+				switch dependency.ImportPath {
+				case "github.com/xoebus/devtool":
+					Ω(dependency.Status).Should(Equal("allowed"))
+					Ω(dependency.Rule.List).Should(Equal("scopes.tool.whitelist"))
+				case "github.com/xoebus/blacklist":
+					Ω(dependency.Status).Should(Equal("banned"))
+					Ω(dependency.Rule.List).Should(Equal("blacklist"))
+				}
+			}
+
+			Ω(scopes).Should(HaveKeyWithValue("github.com/xoebus/devtool", "tool"))
+			Ω(scopes).Should(HaveKeyWithValue("github.com/xoebus/test_only", "test"))
+			Ω(scopes).Should(HaveKeyWithValue("github.com/xoebus/whitelist", "runtime"))
+		})
+	})
+
+	Context("when asked why a package is imported", func() {
+		It("shows the shortest chains of imports from the project's packages and tests", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "why", "github.com/xoebus/whitelist")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("github.com/xoebus/whitelist is imported through:"))
+			Eventually(session).Should(Say(`  github.com/xoebus/modprime -> github.com/xoebus/whitelist`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("follows imports through other dependencies", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "why", "github.com/xoebus/nested")
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`  github.com/xoebus/modprime -> github.com/xoebus/nested/subdir`))
+			Eventually(session).Should(Exit(0))
+		})
+
+		It("fails for packages the project does not import", func() {
+			andersonCommand.Args = append(andersonCommand.Args, "why", "github.com/xoebus/unheard-of")
+			session := runAnderson()
+
+			Eventually(session).Should(Say("github.com/xoebus/unheard-of is not imported by this project"))
+			Eventually(session).Should(Exit(1))
+		})
+	})
+
+	Context("when the config overrides the license of a version", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "modules", "overrides")
+		})
+
+		It("only applies the override at that version", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say(`github.com/xoebus/no-license .*\(MIT, asserted\).*CHECKS OUT`))
+			Eventually(session).Should(Say(`github.com/xoebus/whitelist .*\(MIT\).*CHECKS OUT`))
+			Eventually(session).Should(Exit(0))
+		})
+	})
+
+	Context("when the dependencies are vendored", func() {
+		BeforeEach(func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "modules", "vendored")
+			andersonCommand.Env = append(andersonCommand.Env, "GOFLAGS=-mod=vendor")
+		})
+
+		It("scans the vendored copies", func() {
+			session := runAnderson()
+
+			Eventually(session).Should(Say("github.com/xoebus/no-license.*NO LICENSE"))
+			Eventually(session).Should(Exit(1))
+		})
+	})
+
+	Context("when there is a cache", func() {
+		var dir string
+
+		cachedWorkspaces := func() []string {
+			var cache struct {
+				Workspaces map[string]interface{} `json:"workspaces"`
+			}
+			contents, err := ioutil.ReadFile(filepath.Join(dir, "cache.json"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(json.Unmarshal(contents, &cache)).Should(Succeed())
+
+			workspaces := []string{}
+			for workspace := range cache.Workspaces {
+				workspaces = append(workspaces, filepath.Base(workspace))
+			}
+			return workspaces
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "anderson-cache")
+			Ω(err).ShouldNot(HaveOccurred())
+			andersonCommand.Env = append(andersonCommand.Env, fmt.Sprintf("ANDERSON_CACHE_DIR=%s", dir))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("remembers the packages of projects whose dependencies are all vendored", func() {
+			andersonCommand.Dir = filepath.Join("_ignore", "modules", "vendored")
+			andersonCommand.Env = append(andersonCommand.Env, "GOFLAGS=-mod=vendor")
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+			first := session.Out.Contents()
+
+			Ω(cachedWorkspaces()).Should(Equal([]string{"vendored"}))
+
+			andersonCommand = exec.Command(andersonPath)
+			andersonCommand.Dir = filepath.Join("_ignore", "modules", "vendored")
+			andersonCommand.Env = session.Command.Env
+			session = runAnderson()
+			Eventually(session).Should(Exit(1))
+			Ω(session.Out.Contents()).Should(Equal(first))
+		})
+
+		It("does not remember the packages of modules replaced with a local directory", func() {
+			session := runAnderson()
+			Eventually(session).Should(Exit(1))
+
+			Ω(cachedWorkspaces()).Should(BeEmpty())
+		})
+	})
+})
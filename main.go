@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/cloudfoundry-incubator/candiedyaml"
 	"github.com/mitchellh/colorstring"
@@ -12,100 +15,648 @@ import (
 	"github.com/contraband/anderson/anderson"
 )
 
-type License struct {
-	Type anderson.LicenseStatus
-	Name string
-}
+const (
+	configPath          = ".anderson.yml"
+	defaultBaselinePath = ".anderson-baseline.yml"
+)
+
+// jobs is how many dependencies are resolved and classified at once.
+var jobs int
+
+// deep makes classification also look for other licenses in the packages
+// that are imported from each dependency.
+var deep bool
+
+// cache remembers licenses and workspaces between runs. It is nil when the
+// cache is turned off.
+var cache *anderson.Cache
 
 type Lister interface {
 	ListDependencies() ([]string, error)
 }
 
+type Resolver interface {
+	Resolve(importPath string) (anderson.Location, error)
+}
+
 func main() {
-	config, missingConfig := loadConfig()
-	lister := lister()
+	format := flag.String("format", "text", "output format: text, json, spdx-json, spdx-tag-value, cyclonedx-json or cyclonedx-xml")
+	junit := flag.String("junit", "", "also write a JUnit XML report to this file")
+	explainVerdicts := flag.Bool("explain", false, "show how each dependency's verdict was reached")
+	baselinePath := flag.String("baseline", defaultBaselinePath, "file of accepted findings that do not fail the build")
+	printEffectiveConfig := flag.Bool("print-effective-config", false, "show the config after merging the policies it extends and exit")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "number of dependencies to classify at once")
+	flag.BoolVar(&deep, "deep", false, "look for other licenses in every package that is imported from a dependency")
+	noCache := flag.Bool("no-cache", false, "neither read nor write the cache of licenses and dependencies")
+	clearCache := flag.Bool("clear-cache", false, "remove the cache of licenses and dependencies before running")
+	flag.Parse()
+
+	cache = openCache(*noCache, *clearCache)
+
+	switch flag.Arg(0) {
+	case "notices":
+		notices(flag.Args()[1:])
+		return
+	case "explain":
+		explain(flag.Args()[1:])
+		return
+	case "baseline":
+		baseline(flag.Args()[1:])
+		return
+	case "why":
+		why(flag.Args()[1:])
+		return
+	case "validate":
+		validate()
+		return
+	}
+
+	if *printEffectiveConfig {
+		config, missing := readConfig()
+		if missing {
+			fatalf("There is no %s in this directory", configPath)
+		}
+		if err := config.Write(os.Stdout); err != nil {
+			fatalf("Unable to write config: %s", err)
+		}
+		return
+	}
+
+	switch *format {
+	case "text", "json", "spdx-json", "spdx-tag-value", "cyclonedx-json", "cyclonedx-xml":
+	default:
+		fatalf("Unknown output format: %s", *format)
+	}
+
+	config, expired, missingConfig := loadConfig()
+
+	if *format == "text" {
+		info("Hold still citizen, scanning dependencies for contraband...")
+	}
+
+	report, workspace := scan(config)
+	if baseline, found := loadBaseline(*baselinePath); found {
+		report = baseline.Apply(report)
+	}
+	if len(expired) > 0 {
+		report.ExpiredExceptions = expired
+		report.Passed = false
+	}
+	if packageErrors := workspace.Errors(); len(packageErrors) > 0 {
+		report.PackageErrors = packageErrors
+		report.Passed = false
+	}
+	graph := workspace.ImportGraph()
+	if !*explainVerdicts {
+		for i := range report.Dependencies {
+			report.Dependencies[i].Trace = nil
+		}
+	}
+	if !report.Passed && (*format == "text" || *format == "json") {
+		for i, dependency := range report.Dependencies {
+			if dependency.FailsBuild {
+				report.Dependencies[i].ImportedBy = graph.Why(dependency.ImportPath)
+			}
+		}
+	}
+
+	switch *format {
+	case "json":
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			fatalf("Unable to write report: %s", err)
+		}
+	case "spdx-json":
+		document := anderson.NewSPDXDocument(rootName(), report, time.Now())
+		if err := document.WriteJSON(os.Stdout); err != nil {
+			fatalf("Unable to write SPDX document: %s", err)
+		}
+	case "spdx-tag-value":
+		document := anderson.NewSPDXDocument(rootName(), report, time.Now())
+		if err := document.WriteTagValue(os.Stdout); err != nil {
+			fatalf("Unable to write SPDX document: %s", err)
+		}
+	case "cyclonedx-json":
+		bom := anderson.NewCycloneDXBOM(rootName(), report, graph, time.Now())
+		if err := bom.WriteJSON(os.Stdout); err != nil {
+			fatalf("Unable to write CycloneDX BOM: %s", err)
+		}
+	case "cyclonedx-xml":
+		bom := anderson.NewCycloneDXBOM(rootName(), report, graph, time.Now())
+		if err := bom.WriteXML(os.Stdout); err != nil {
+			fatalf("Unable to write CycloneDX BOM: %s", err)
+		}
+	default:
+		printReport(report, missingConfig, *explainVerdicts)
+	}
+
+	if *junit != "" {
+		if err := writeJUnit(*junit, report); err != nil {
+			fatalf("Unable to write JUnit report: %s", err)
+		}
+	}
+
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
+
+// scan lists the dependencies of the current package and classifies the
+// license of each of them under the policy for its scope. The workspace that
+// the dependencies and their scopes came from is returned alongside the
+// report.
+func scan(config anderson.Config) (anderson.Report, anderson.Workspace) {
+	goEnv, _ := anderson.LoadGoEnv()
+	workspace := loadWorkspace()
+	lister := lister(workspace)
+	resolver := resolver(goEnv)
 	classifier := anderson.LicenseClassifier{
 		Config: config,
+		Cache:  cache,
 	}
 
-	info("Hold still citizen, scanning dependencies for contraband...")
 	dependencies, err := lister.ListDependencies()
 	if err != nil {
-		fatalf(err.Error())
+		fatalf("%s", err)
 	}
 
-	failed := false
-	classified := map[string]License{}
-	for _, importPath := range dependencies {
-		path, err := anderson.LookGopath(importPath)
-		if err != nil {
-			fatalf("Could not find %s in your GOPATH...", importPath)
+	scopes := workspace.ImportGraph().Scopes()
+
+	results := make([]anderson.Dependency, len(dependencies))
+	anderson.Parallel(jobs, len(dependencies), func(i int) {
+		importPath := dependencies[i]
+		results[i] = classify(goEnv, resolver, classifier, importPath, scopes.Of(anderson.VendorlessPath(importPath)), workspace.PackageDirs(importPath))
+	})
+
+	classified := map[string]anderson.Dependency{}
+	for _, dependency := range results {
+		if existing, ok := classified[dependency.LicensePath]; ok {
+			dependency = anderson.MergeDependencies(existing, dependency)
 		}
+		classified[dependency.LicensePath] = dependency
+	}
 
-		licenseType, licenseDeclarationPath, licenseName, err := classifier.Classify(path, importPath)
-		failed = failed || licenseType.FailsBuild()
+	merged := []anderson.Dependency{}
+	for _, dependency := range classified {
+		merged = append(merged, dependency)
+	}
 
-		containingGopath, err := anderson.ContainingGopath(importPath)
-		if err != nil {
-			fatalf("Unable to find containing GOPATH for %s: %s", licenseDeclarationPath, err)
+	saveCache()
+
+	return anderson.NewReport(merged), workspace
+}
+
+func classify(goEnv anderson.GoEnv, resolver Resolver, classifier anderson.LicenseClassifier, importPath string, scope anderson.Scope, dirs []string) anderson.Dependency {
+	location, err := resolver.Resolve(importPath)
+	if err != nil {
+		if goEnv.ModulesEnabled() {
+			fatalf("Could not find %s in your modules...", importPath)
 		}
+		fatalf("Could not find %s in your GOPATH...", importPath)
+	}
 
-		relPath, err := filepath.Rel(filepath.Join(containingGopath, "src"), licenseDeclarationPath)
-		if err != nil {
-			fatalf("Unable to create relative path for %s: %s", licenseDeclarationPath, err)
+	importPath = anderson.VendorlessPath(importPath)
+	classifier.Scope = scope
+	classifier.Version = location.Version
+	classification, err := classifier.Classify(location.Dir, location.Root, importPath)
+	if deep {
+		if len(dirs) == 0 {
+			dirs = []string{location.Dir}
+		}
+		classification = classifier.ScanDeep(classification, importPath, dirs)
+	}
+
+	relPath, err := location.ImportPath(classification.Path)
+	if err != nil {
+		fatalf("Unable to create relative path for %s: %s", classification.Path, err)
+	}
+
+	dependency := anderson.NewDependency(importPath, relPath, classification)
+	dependency.Version = location.Version
+	dependency.Scope = scope
+	dependency.Unvendored = location.UnvendoredLicenseFiles(classification.Files)
+	for _, file := range dependency.Unvendored {
+		dependency.Trace = append(dependency.Trace, fmt.Sprintf("%s has %s upstream but it was not vendored", importPath, file))
+	}
+
+	return dependency
+}
+
+// explain shows how the verdict for each of the given import paths was
+// reached.
+func explain(importPaths []string) {
+	if len(importPaths) == 0 {
+		fatalf("Usage: anderson explain <import path>...")
+	}
+
+	config, _, missingConfig := loadConfig()
+	goEnv, _ := anderson.LoadGoEnv()
+	resolver := resolver(goEnv)
+	classifier := anderson.LicenseClassifier{
+		Config: config,
+		Cache:  cache,
+	}
+	workspace := loadWorkspace()
+	scopes := workspace.ImportGraph().Scopes()
+
+	for _, importPath := range importPaths {
+		dependency := classify(goEnv, resolver, classifier, importPath, scopes.Of(anderson.VendorlessPath(importPath)), workspace.PackageDirs(importPath))
+		printDependency(dependency, missingConfig, true)
+	}
+
+	saveCache()
+}
+
+// why shows the shortest chains of imports that lead from the current
+// project to each of the given import paths.
+func why(importPaths []string) {
+	if len(importPaths) == 0 {
+		fatalf("Usage: anderson why <import path>...")
+	}
+
+	if isStdinPipe() {
+		fatalf("anderson why needs to load the packages itself and cannot read them from STDIN")
+	}
+
+	graph := loadWorkspace().ImportGraph()
+	saveCache()
+
+	found := true
+	for _, importPath := range importPaths {
+		chains := graph.Why(importPath)
+		if len(chains) == 0 {
+			say(fmt.Sprintf("[red]> %s is not imported by this project", importPath))
+			found = false
+			continue
+		}
+
+		info(fmt.Sprintf("%s is imported through:", importPath))
+		for _, chain := range chains {
+			say(fmt.Sprintf("[white]  %s", strings.Join(chain, " -> ")))
+		}
+	}
+
+	if !found {
+		os.Exit(1)
+	}
+}
+
+// validate checks .anderson.yml for mistakes. Exceptions are checked against
+// the dependencies that the project has now.
+func validate() {
+	if _, err := os.Stat(configPath); err != nil {
+		fatalf("There is no %s in this directory", configPath)
+	}
+
+	report, _ := scan(anderson.Config{})
+
+	problems, err := anderson.ValidateConfig(configPath, report.Dependencies, time.Now())
+	if err != nil {
+		fatalf("Unable to read your config: %s", err)
+	}
+
+	if len(problems) == 0 {
+		info(fmt.Sprintf("%s looks good", configPath))
+		return
+	}
+
+	for _, problem := range problems {
+		location := configPath
+		if problem.Line > 0 {
+			location = fmt.Sprintf("%s:%s", configPath, problem.Position)
+		}
+		say(fmt.Sprintf("[red]> %s: %s", location, problem.Message))
+	}
+
+	if len(problems) == 1 {
+		fatalf("Found 1 problem in %s", configPath)
+	}
+	fatalf("Found %d problems in %s", len(problems), configPath)
+}
+
+// baseline records the findings that currently fail the build so that later
+// runs only fail on new ones.
+func baseline(args []string) {
+	flags := flag.NewFlagSet("baseline", flag.ExitOnError)
+	path := flags.String("file", defaultBaselinePath, "file to write the baseline to")
+	flags.Parse(args)
+
+	config, _, _ := loadConfig()
+	report, _ := scan(config)
+	baseline := anderson.NewBaseline(report)
+
+	file, err := os.Create(*path)
+	if err != nil {
+		fatalf("Unable to write baseline: %s", err)
+	}
+	defer file.Close()
+
+	if err := candiedyaml.NewEncoder(file).Encode(baseline); err != nil {
+		fatalf("Unable to write baseline: %s", err)
+	}
+
+	info(fmt.Sprintf("Recorded %d findings in %s", len(baseline.Entries), *path))
+}
+
+// notices writes the license texts and notices of every dependency that ships
+// as a single attribution document.
+func notices(args []string) {
+	flags := flag.NewFlagSet("notices", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text or markdown")
+	allScopes := flags.Bool("all-scopes", false, "include test and tool dependencies")
+	flags.Parse(args)
+
+	switch *format {
+	case "text", "markdown":
+	default:
+		fatalf("Unknown output format: %s", *format)
+	}
+
+	config, _, _ := loadConfig()
+	report, _ := scan(config)
+
+	document, err := anderson.NewNotices(rootName(), report, *allScopes)
+	if err != nil {
+		fatalf("Unable to collect notices: %s", err)
+	}
+
+	if *format == "markdown" {
+		err = document.WriteMarkdown(os.Stdout)
+	} else {
+		err = document.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fatalf("Unable to write notices: %s", err)
+	}
+}
+
+func writeJUnit(path string, report anderson.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := anderson.NewJUnitReport(rootName(), report, time.Now()).WriteXML(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// printReport lists the dependencies grouped by status, with the ones that
+// fail the build first, and then sums everything up.
+func printReport(report anderson.Report, missingConfig bool, explain bool) {
+	if missingConfig {
+		for _, dependency := range report.Dependencies {
+			printDependency(dependency, missingConfig, explain)
+		}
+	} else {
+		for _, group := range report.Groups() {
+			fmt.Println()
+			say(fmt.Sprintf("[%s]%s (%d)", group.Status.Color(), group.Status.Message(), len(group.Dependencies)))
+			for _, dependency := range group.Dependencies {
+				printDependency(dependency, missingConfig, explain)
+			}
 		}
+	}
 
-		classified[relPath] = License{
-			Type: licenseType,
-			Name: licenseName,
+	fmt.Println()
+	info(fmt.Sprintf("Checked %d dependencies", report.Total))
+	if !missingConfig {
+		for _, group := range report.Groups() {
+			say(fmt.Sprintf("[%s]%5d %s", group.Status.Color(), len(group.Dependencies), group.Status.Message()))
 		}
+		fmt.Println()
+	}
+	for _, count := range report.LicenseCounts() {
+		say(fmt.Sprintf("[white]%5d %s", count.Count, count.License))
 	}
 
-	for relPath, license := range classified {
-		var message string
-		var messageLen int
+	if failures := report.Failures(); len(failures) > 0 && !missingConfig {
+		fmt.Println()
+		say("[red]> These dependencies failed the build:")
+		for _, dependency := range failures {
+			say(fmt.Sprintf("[white]  %s (%s) [%s]%s", displayName(dependency), dependency.License, dependency.Status.Color(), dependency.Status.Message()))
+		}
+	}
 
-		if missingConfig {
-			message = fmt.Sprintf("[white]%s", license.Name)
-			messageLen = len(license.Name)
-		} else {
-			message = fmt.Sprintf("(%s) [%s]%10s", license.Name, license.Type.Color(), license.Type.Message())
-			messageLen = len(license.Name) + len("() ") + 9 // length of all messages
+	if len(report.ExpiredExceptions) > 0 {
+		fmt.Println()
+		say("[red]> These exceptions have expired and no longer apply:")
+		for _, exception := range report.ExpiredExceptions {
+			say(fmt.Sprintf("[white]  %s expired on %s", exception.Path, exception.Expires))
+			if exception.ApprovedBy != "" {
+				fmt.Printf("    approved by %s\n", exception.ApprovedBy)
+			}
+			if exception.Reason != "" {
+				fmt.Printf("    reason given: %s\n", exception.Reason)
+			}
 		}
+	}
 
-		totalSize := messageLen + len(relPath)
-		whitespace := " "
-		if totalSize < 80 {
-			whitespace = strings.Repeat(" ", 80-totalSize)
+	if len(report.PackageErrors) > 0 {
+		fmt.Println()
+		say("[red]> These packages could not be loaded:")
+		for _, packageError := range report.PackageErrors {
+			say(fmt.Sprintf("[white]  %s", packageError.ImportPath))
+			fmt.Printf("    %s\n", strings.Replace(packageError.Err, "\n", "\n    ", -1))
 		}
+	}
 
-		say(fmt.Sprintf("[white]%s%s%s", relPath, whitespace, message))
+	if len(report.StaleBaseline) > 0 {
+		fmt.Println()
+		info("These baseline entries no longer match anything and can be removed:")
+		for _, entry := range report.StaleBaseline {
+			say(fmt.Sprintf("[white]  %s (%s) %s", entry.ImportPath, entry.License, entry.Status))
+		}
 	}
+}
 
-	if failed {
-		os.Exit(1)
+func printDependency(dependency anderson.Dependency, missingConfig bool, explain bool) {
+	var message string
+	var messageLen int
+
+	licenseName := dependency.License
+	if dependency.ElectedLicense != licenseName {
+		licenseName = fmt.Sprintf("%s, elected %s", licenseName, dependency.ElectedLicense)
+	}
+	if dependency.Asserted {
+		licenseName += ", asserted"
+	}
+
+	if missingConfig {
+		message = fmt.Sprintf("[white]%s", licenseName)
+		messageLen = len(licenseName)
+	} else if dependency.Baselined {
+		message = fmt.Sprintf("(%s) [dark_gray]%10s (baseline)", licenseName, dependency.Status.Message())
+		messageLen = len(licenseName) + len("()  (baseline)") + 9
+	} else {
+		message = fmt.Sprintf("(%s) [%s]%10s", licenseName, dependency.Status.Color(), dependency.Status.Message())
+		messageLen = len(licenseName) + len("() ") + 9 // length of all messages
+	}
+
+	name := displayName(dependency)
+	totalSize := messageLen + len(name)
+	whitespace := " "
+	if totalSize < 80 {
+		whitespace = strings.Repeat(" ", 80-totalSize)
+	}
+
+	say(fmt.Sprintf("[white]%s%s%s", name, whitespace, message))
+
+	for _, chain := range dependency.ImportedBy {
+		fmt.Printf("    imported through %s\n", strings.Join(chain, " -> "))
+	}
+
+	for _, file := range dependency.LicenseFiles {
+		if file.Header {
+			fmt.Printf("    license stated in the header of %s\n", filepath.Base(file.Path))
+		}
+	}
+
+	for _, file := range dependency.Nested {
+		say(fmt.Sprintf("[yellow]    %s found in %s", file.License, file.Path))
+	}
+
+	for _, file := range dependency.Unvendored {
+		say(fmt.Sprintf("[yellow]    %s is missing from the vendored copy but upstream has it", file))
+	}
+
+	if explain {
+		for _, step := range dependency.Trace {
+			fmt.Printf("    %s\n", step)
+		}
+	}
+}
+
+// displayName is the path that a dependency is shown under. Dependencies
+// that do not ship are marked with their scope.
+func displayName(dependency anderson.Dependency) string {
+	if dependency.Scope.Ships() {
+		return dependency.LicensePath
 	}
+	return fmt.Sprintf("%s [%s]", dependency.LicensePath, dependency.Scope)
 }
 
-func loadConfig() (config anderson.Config, missing bool) {
-	configFile, err := os.Open(".anderson.yml")
+func loadBaseline(path string) (baseline anderson.Baseline, found bool) {
+	baselineFile, err := os.Open(path)
 	if err != nil {
+		return baseline, false
+	}
+	defer baselineFile.Close()
+
+	if err := candiedyaml.NewDecoder(baselineFile).Decode(&baseline); err != nil {
+		fatalf("Looks like your %s file is invalid YAML!", path)
+	}
+
+	return baseline, true
+}
+
+// loadConfig reads .anderson.yml. Exceptions that have expired are left
+// out of the config and returned separately.
+func loadConfig() (config anderson.Config, expired []anderson.Exception, missing bool) {
+	config, missing = readConfig()
+	config, expired = config.ExpiredExceptions(time.Now())
+	return config, expired, missing
+}
+
+// readConfig reads .anderson.yml and merges in the policies that it extends.
+func readConfig() (config anderson.Config, missing bool) {
+	if _, err := os.Stat(configPath); err != nil {
 		return config, true
 	}
 
-	if err := candiedyaml.NewDecoder(configFile).Decode(&config); err != nil {
-		fatalf("Looks like your .anderson.yml file is invalid YAML!")
+	config, err := anderson.LoadConfig(configPath)
+	if err != nil {
+		fatalf("Unable to load your config: %s", err)
 	}
 
 	return config, false
 }
 
-func lister() Lister {
+func rootName() string {
+	name, err := anderson.CurrentPackageName()
+	if err == nil && name != "" {
+		return name
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "unknown"
+	}
+
+	return filepath.Base(wd)
+}
+
+// loadWorkspace loads the project's packages and everything they import in
+// one go. Dependencies read from stdin have no workspace to speak of.
+func loadWorkspace() anderson.Workspace {
+	if isStdinPipe() {
+		return anderson.Workspace{}
+	}
+
+	workspace, err := cache.LoadWorkspace()
+	if err != nil {
+		fatalf("Unable to load packages: %s", err)
+	}
+
+	return workspace
+}
+
+// openCache opens the cache in ANDERSON_CACHE_DIR or the user's cache
+// directory, unless it has been turned off. Clearing it comes first so that
+// the run after clearing starts from scratch.
+func openCache(disabled bool, clear bool) *anderson.Cache {
+	dir := os.Getenv("ANDERSON_CACHE_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = anderson.DefaultCacheDir(); err != nil {
+			return nil
+		}
+	}
+
+	if clear {
+		if err := anderson.ClearCache(dir); err != nil {
+			fatalf("Unable to clear the cache: %s", err)
+		}
+	}
+
+	if disabled {
+		return nil
+	}
+
+	return anderson.OpenCache(dir)
+}
+
+// saveCache writes out what this run added to the cache. The cache only
+// saves time, so failing to write it is not worth failing the run over.
+func saveCache() {
+	_ = cache.Save()
+}
+
+func lister(workspace anderson.Workspace) Lister {
 	if isStdinPipe() {
 		return anderson.StdinLister{}
 	}
 
-	return anderson.PackageLister{}
+	return workspace
+}
+
+func resolver(goEnv anderson.GoEnv) Resolver {
+	if !goEnv.ModulesEnabled() {
+		resolver, err := anderson.NewGopathResolver()
+		if err != nil {
+			fatalf("Unable to find your GOPATH: %s", err)
+		}
+		return resolver
+	}
+
+	resolver, err := anderson.NewModuleResolver(goEnv)
+	if err != nil {
+		fatalf("Unable to load modules: %s", err)
+	}
+
+	return resolver
 }
 
 func isStdinPipe() bool {
@@ -114,7 +665,7 @@ func isStdinPipe() bool {
 }
 
 func fatalf(err string, args ...interface{}) {
-	message := fmt.Sprintf(err, args)
+	message := fmt.Sprintf(err, args...)
 	say(fmt.Sprintf("[red]> %s", message))
 	os.Exit(1)
 }